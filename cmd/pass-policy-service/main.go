@@ -17,6 +17,7 @@ func main() {
 	app.Commands = []cli.Command{
 		serve(),
 		validate(),
+		migrate(),
 	}
 	err := app.Run(os.Args)
 	if err != nil {
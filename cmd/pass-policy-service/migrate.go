@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/urfave/cli"
+)
+
+type migrateOpts struct {
+	write bool
+}
+
+func migrate() cli.Command {
+	opts := migrateOpts{}
+
+	return cli.Command{
+		Name:  "migrate",
+		Usage: "Migrate policy rules files to the latest schema",
+		Description: `
+			Given a list of policy rules files, migrate will rewrite each document
+			so that it conforms to the latest schema supported by this policy service.
+			Documents must be valid with respect to the schema they declare, and
+			must be written in JSON.
+
+			By default, migrated documents are printed to standard output.  Use -w
+			to overwrite the files instead.
+		`,
+		ArgsUsage: "files",
+		Flags: []cli.Flag{
+			cli.BoolFlag{
+				Name:        "write, w",
+				Usage:       "Write migrated documents back to their files instead of standard output",
+				Destination: &opts.write,
+			},
+		},
+		Action: func(c *cli.Context) error {
+			return migrateAction(opts, c.Args())
+		},
+	}
+}
+
+func migrateAction(opts migrateOpts, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("migrate requires at least one file")
+	}
+
+	var lastErr error
+
+	for _, instance := range args {
+		info, err := os.Stat(instance)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		content, err := ioutil.ReadFile(instance)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		migrated, err := rule.Migrate(content)
+		if err != nil {
+			log.Printf("Migration failed: %s: %v", instance, err)
+			lastErr = err
+			continue
+		}
+
+		if !opts.write {
+			_, _ = os.Stdout.Write(migrated)
+			continue
+		}
+
+		err = ioutil.WriteFile(instance, migrated, info.Mode())
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		log.Printf("Migrated: %s", instance)
+	}
+
+	return lastErr
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+func TestMigrateCLI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	content, _ := ioutil.ReadFile("../../rule/testdata/good.json")
+	file := filepath.Join(dir, "rules.json")
+	_ = ioutil.WriteFile(file, content, 0644)
+
+	// Invoke the main function with args manually
+	os.Args = []string{"pass-policy-service", "migrate", "-w", file}
+
+	// If it exits in error, capture the error
+	fatalf = func(f string, a ...interface{}) {
+		t.Fatalf(f, a...)
+	}
+
+	main()
+
+	migrated, _ := ioutil.ReadFile(file)
+	rules, err := rule.Validate(migrated)
+	if err != nil {
+		t.Fatalf("migrated file is invalid: %+v", err)
+	}
+
+	if rules.Schema != rule.LatestSchema {
+		t.Fatalf("expected schema %s, got %s", rule.LatestSchema, rules.Schema)
+	}
+}
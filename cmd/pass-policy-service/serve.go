@@ -2,10 +2,13 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/oa-pass/pass-policy-service/web"
 	"github.com/pkg/errors"
@@ -18,6 +21,10 @@ type serveOpts struct {
 	username       string
 	passwd         string
 	port           int
+	batchWorkers   int
+	adminUsername  string
+	adminPasswd    string
+	reloadInterval time.Duration
 }
 
 func serve() cli.Command {
@@ -27,7 +34,7 @@ func serve() cli.Command {
 		Name:  "serve",
 		Usage: "Serve the PASS policy service over http",
 		Description: `
-			An optional configuration file may be provided as an argument
+			An optional configuration file (in JSON or YAML) may be provided as an argument
 		`,
 		ArgsUsage: "[ file ]",
 		Flags: []cli.Flag{
@@ -61,6 +68,31 @@ func serve() cli.Command {
 				EnvVar:      "POLICY_SERVICE_PORT",
 				Destination: &opts.port,
 			},
+			cli.IntFlag{
+				Name:        "batch-workers",
+				Usage:       "Number of submissions of a batch request evaluated at once",
+				EnvVar:      "POLICY_SERVICE_BATCH_WORKERS",
+				Destination: &opts.batchWorkers,
+			},
+			cli.StringFlag{
+				Name:        "admin-username",
+				Usage:       "Username for basic auth to administrative endpoints.  If absent, they are disabled",
+				EnvVar:      "POLICY_SERVICE_ADMIN_USER",
+				Destination: &opts.adminUsername,
+			},
+			cli.StringFlag{
+				Name:        "admin-password",
+				Usage:       "Password for basic auth to administrative endpoints",
+				EnvVar:      "POLICY_SERVICE_ADMIN_PASSWORD",
+				Destination: &opts.adminPasswd,
+			},
+			cli.DurationFlag{
+				Name:        "reload-interval",
+				Usage:       "Interval between checks of the rules file for changes (0 disables checking)",
+				EnvVar:      "POLICY_SERVICE_RELOAD_INTERVAL",
+				Value:       10 * time.Second,
+				Destination: &opts.reloadInterval,
+			},
 		},
 		Action: func(c *cli.Context) error {
 			return serveAction(opts, c.Args())
@@ -82,16 +114,14 @@ func serveAction(opts serveOpts, args []string) error {
 		}
 	}
 
-	rules, err := ioutil.ReadFile(args[0])
-	if err != nil {
-		return fmt.Errorf("error reading %s: %s", args[0], err.Error())
-	}
+	metrics := web.NewMetrics()
 
-	policyService, err := web.NewPolicyService(rules, &web.InternalPassClient{
+	policyService, err := web.NewPolicyServiceFromFile(args[0], &web.InternalPassClient{
 		Requester:       &http.Client{},
 		ExternalBaseURI: opts.publicBaseURI,
 		InternalBaseURI: opts.privateBaseURI,
 		Credentials:     credentials,
+		Metrics:         metrics,
 	})
 	if err != nil {
 		return errors.Wrapf(err, "could not initialize policy service")
@@ -101,9 +131,35 @@ func serveAction(opts serveOpts, args []string) error {
 		Public:  opts.publicBaseURI,
 		Private: opts.privateBaseURI,
 	}
+	policyService.BatchWorkers = opts.batchWorkers
+	policyService.Metrics = metrics
+
+	// Reload the rules when the file changes, or on SIGHUP
+	rules := policyService.RulesFile
+	rules.Metrics = metrics
+	_, version := rules.Rules()
+	metrics.SetRulesVersion(version)
+	log.Printf("Loaded rules from %s, version %s", rules.Path, version)
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go rules.Watch(opts.reloadInterval, reload, nil)
+
+	if opts.adminUsername != "" {
+		policyService.Admin = &web.Credentials{
+			Username: opts.adminUsername,
+			Password: opts.adminPasswd,
+		}
+	}
 
-	http.HandleFunc("/policies", policyService.RequestPolicies)
-	http.HandleFunc("/repositories", policyService.RequestRepositories)
+	http.HandleFunc("/policies", metrics.Instrument("policies", policyService.RequestPolicies))
+	http.HandleFunc("/repositories", metrics.Instrument("repositories", policyService.RequestRepositories))
+	http.HandleFunc("/compliance", metrics.Instrument("compliance", policyService.RequestCompliance))
+	http.HandleFunc("/batch", metrics.Instrument("batch", policyService.RequestBatch))
+	http.HandleFunc("/explain", metrics.Instrument("explain", policyService.RequestExplain))
+	http.HandleFunc("/healthz", policyService.RequestHealth)
+	http.HandleFunc("/readyz", policyService.RequestReadiness)
+	http.HandleFunc("/metrics", policyService.RequestMetrics)
 
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", opts.port))
 	if err != nil {
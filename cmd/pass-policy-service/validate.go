@@ -2,7 +2,6 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
 	"strings"
 
@@ -20,8 +19,12 @@ func validate() cli.Command {
 			the documents and validate it with respect to the schema used by this
 			policy service.
 
-			Note, the documents will be validated against schemas supported by this 
-			application regardless of any schema declarations in the file. 
+			Each document is validated against the schema it declares in $schema,
+			which must be one of the schemas supported by this application.  Any
+			documents it includes are validated as well.
+
+			Documents may be written in JSON or YAML.  Files with a .yaml or .yml
+			extension are read as YAML, as is any document that isn't a JSON object.
 		`,
 		ArgsUsage: "files",
 		Action: func(c *cli.Context) error {
@@ -38,13 +41,7 @@ func validateAction(args []string) error {
 	var lastErr error
 
 	for _, instance := range args {
-		content, err := ioutil.ReadFile(instance)
-		if err != nil {
-			lastErr = err
-			continue
-		}
-
-		_, err = rule.Validate(content)
+		_, err := rule.ValidateFile(instance)
 		if err == nil {
 			log.Printf("Validation OK: %s", instance)
 		} else {
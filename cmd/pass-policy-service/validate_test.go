@@ -13,6 +13,7 @@ func TestValidateCLI(t *testing.T) {
 	}{
 		{"goodData", "../../rule/testdata/good.json", false},
 		{"badData", "../../rule/testdata/bad.json", true},
+		{"yamlData", "../../rule/testdata/good_2.0.yaml", false},
 	}
 
 	for _, c := range cases {
@@ -6,8 +6,10 @@ require (
 	github.com/go-test/deep v1.0.1
 	github.com/gobuffalo/packr/v2 v2.1.0
 	github.com/pkg/errors v0.8.1
+	github.com/prometheus/client_golang v1.1.0
 	github.com/qri-io/jsonschema v0.0.0-20190413152851-094d15abc20e
 	github.com/sergi/go-diff v1.0.0 // indirect
 	github.com/urfave/cli v1.20.0
 	golang.org/x/tools v0.0.0-20190411180116-681f9ce8ac52 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 )
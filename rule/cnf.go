@@ -0,0 +1,180 @@
+package rule
+
+import (
+	"sort"
+)
+
+// clause is a disjunction of repositories:  it is satisfied by depositing into any one of them.
+// A clause containing "*" is satisfied by depositing anywhere at all.
+type clause []Repository
+
+// formula is a conjunction of clauses, i.e. a boolean formula over repositories in conjunctive
+// normal form.  Repositories are never negated, so depositing into more repositories never
+// violates a formula that was satisfied.
+type formula []clause
+
+// isSoft determines if a clause contains "*"
+func (c clause) isSoft() bool {
+	return repoListContains(c, Repository{ID: "*"})
+}
+
+// subsumes determines if every member of the clause is a member of the other.  If so, satisfying
+// the clause satisfies the other, so the other clause is redundant.
+func (c clause) subsumes(other clause) bool {
+	for _, member := range c {
+		if !repoListContains(other, member) {
+			return false
+		}
+	}
+	return true
+}
+
+// sources returns the sources of all members of the clause
+func (c clause) sources() []Source {
+	var sources []Source
+	for _, member := range c {
+		sources = mergeSources(sources, member.Sources)
+	}
+	return sources
+}
+
+// bySource sorts a copy of the clauses of a formula by the sources of their members, so that
+// each policy has the clauses it calls for.  A one-of group spanning policies is called for by
+// each of them.  Only the IDs of members are copied.
+func (f formula) bySource() map[Source]formula {
+	clauses := make(map[Source]formula)
+	for _, c := range f {
+		copied := make(clause, 0, len(c))
+		for _, member := range c {
+			copied = append(copied, Repository{ID: member.ID})
+		}
+
+		for _, source := range c.sources() {
+			clauses[source] = append(clauses[source], copied)
+		}
+	}
+
+	return clauses
+}
+
+// toFormula translates a list of policies into a formula, along with the repositories that are
+// explicitly optional.  Each policy contributes a clause of the repositories whose requirement
+// level is inferred, a clause for each explicitly required repository, and a clause of its explicitly
+// one-of repositories.  Labeled one-of groups contribute a clause of their members from all policies.
+// Each repository is annotated with the policy that calls for it, if the policy is identified.
+func toFormula(policies []Policy) (f formula, optional []Repository) {
+	var groupLabels []string
+	groups := make(map[string]clause)
+
+	for _, p := range policies {
+		var implicit, oneOf clause
+
+		for _, repo := range p.Repositories {
+			level, group := repo.Requirement, repo.Group
+			repo.Requirement, repo.Group, repo.Rank = "", "", nil
+			if p.ID != "" {
+				repo.Sources = []Source{{PolicyID: p.ID, RuleID: p.RuleID}}
+			}
+
+			switch level {
+			case RequirementRequired:
+				f = append(f, clause{repo})
+			case RequirementOptional:
+				optional = append(optional, repo)
+			case RequirementOneOf:
+				if group == "" {
+					oneOf = append(oneOf, repo)
+					continue
+				}
+				if _, ok := groups[group]; !ok {
+					groupLabels = append(groupLabels, group)
+				}
+				groups[group] = append(groups[group], repo)
+			default:
+				implicit = append(implicit, repo)
+			}
+		}
+
+		// A policy listing nothing but "*" has no requirements
+		if len(implicit) > 1 || (len(implicit) == 1 && implicit[0].ID != "*") {
+			f = append(f, implicit)
+		}
+
+		if len(oneOf) > 0 {
+			f = append(f, oneOf)
+		}
+	}
+
+	for _, label := range groupLabels {
+		f = append(f, groups[label])
+	}
+
+	return f, optional
+}
+
+// simplify returns an equivalent formula consisting of only its minimal clauses:  duplicate clauses
+// are merged, and clauses subsumed by other clauses are removed.  Since a single-membered clause
+// subsumes every clause containing its member, this includes removing clauses satisfied by a
+// required repository.  Members of removed clauses that are no longer part of the formula are
+// returned as dropped.
+//
+// The policies calling for a removed clause call for the members of the clause that subsumed it
+// too, so those members are annotated with their sources.  That attributes repositories to
+// policies, but the policies are not necessarily met by the same choices, so compliance is checked
+// against what each policy calls for instead.
+func (f formula) simplify() (minimal formula, dropped []Repository) {
+
+	// Merge duplicates
+	var keys []string
+	unique := make(map[string]clause, len(f))
+	for _, c := range f {
+		c = uniqueRepos(c)
+		key := repoListKey(c)
+
+		if existing, ok := unique[key]; ok {
+			mergeMemberSources(existing, c)
+			continue
+		}
+		keys = append(keys, key)
+		unique[key] = c
+	}
+
+	// Consider smaller clauses first, so that any clause that could subsume another has
+	// already been kept by the time the other is considered
+	sort.Slice(keys, func(i, j int) bool {
+		if len(unique[keys[i]]) != len(unique[keys[j]]) {
+			return len(unique[keys[i]]) < len(unique[keys[j]])
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		c := unique[key]
+
+		subsumer := -1
+		for i := range minimal {
+			if minimal[i].subsumes(c) {
+				subsumer = i
+				break
+			}
+		}
+
+		if subsumer < 0 {
+			minimal = append(minimal, c)
+			continue
+		}
+
+		sources := c.sources()
+		for i := range minimal[subsumer] {
+			minimal[subsumer][i].Sources = mergeSources(minimal[subsumer][i].Sources, sources)
+		}
+
+		for _, member := range c {
+			if !repoListContains(minimal[subsumer], member) {
+				dropped = append(dropped, member)
+			}
+		}
+	}
+
+	return minimal, dropped
+}
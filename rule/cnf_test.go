@@ -0,0 +1,265 @@
+package rule_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+// universe of repositories the exhaustive tests draw from, including "*"
+var universe = []string{"a", "b", "c", "*"}
+
+// TestAnalyzeEquivalence exhaustively verifies, for every list of up to three policies over a
+// small universe of repositories, that the analyzed requirements are met by exactly the same
+// choices of repositories as the policies themselves, that each policy is found to be satisfied by
+// exactly the choices that meet it, and that the requirements are in simplest form.
+//
+// Policies list repositories with inferred levels, or explicit ones, including one-of groups
+// labelled to span policies.  A policy is met by depositing into any repository it lists with an
+// inferred level, any of its one-of repositories, and each of its required ones.  A labelled group
+// is met by depositing into any of its members, and "*" is met by depositing anywhere.  Since a
+// submission must be deposited somewhere, if the only policies met that way suggest repositories,
+// one of those must be chosen.
+func TestAnalyzeEquivalence(t *testing.T) {
+	levels := []rule.Repository{
+		{},
+		{Requirement: rule.RequirementOptional},
+		{Requirement: rule.RequirementRequired},
+		{Requirement: rule.RequirementOneOf},
+		{Requirement: rule.RequirementOneOf, Group: "g"},
+	}
+
+	var candidates []rule.Policy
+	for mask := 1; mask < 1<<uint(len(universe)); mask++ {
+		for _, level := range levels {
+			var repos []rule.Repository
+			for i, id := range universe {
+				if mask&(1<<uint(i)) != 0 {
+					repos = append(repos, rule.Repository{ID: id, Requirement: level.Requirement, Group: level.Group})
+				}
+			}
+			candidates = append(candidates, rule.Policy{Repositories: repos})
+		}
+	}
+
+	// The analysis doesn't depend on the order of policies, so each combination is verified once
+	var check func(policies []rule.Policy, from int)
+	check = func(policies []rule.Policy, from int) {
+		verifyEquivalence(t, policies)
+		if len(policies) == 3 {
+			return
+		}
+		for i := from; i < len(candidates); i++ {
+			p := candidates[i]
+			p.ID = fmt.Sprintf("p%d", len(policies))
+			check(append(policies[:len(policies):len(policies)], p), i)
+		}
+	}
+
+	check(nil, 0)
+}
+
+func verifyEquivalence(t *testing.T, policies []rule.Policy) {
+	analyzed := rule.AnalyzeRequirements(policies)
+	describe := func() string {
+		return fmt.Sprintf("policies %s analyzed as %+v", describePolicies(policies), *analyzed)
+	}
+
+	// The original constraints, as clauses of repositories.  Clauses containing "*" are met by
+	// depositing anywhere, but suggest their other members.
+	var clauses [][]string
+	var suggested []string
+	add := func(clause []string) {
+		var others []string
+		for _, id := range clause {
+			if id != "*" {
+				others = append(others, id)
+			}
+		}
+
+		if len(others) < len(clause) {
+			suggested = append(suggested, others...)
+		} else if len(clause) > 0 {
+			clauses = append(clauses, clause)
+		}
+	}
+
+	// What each policy calls for, by policy ID
+	own := make(map[string][][]string)
+
+	var group []string
+	var grouped []string
+	mentioned := make(map[string]bool)
+	for _, p := range policies {
+		var implicit, oneOf []string
+		for _, repo := range p.Repositories {
+			if repo.ID != "*" {
+				mentioned[repo.ID] = true
+			}
+
+			switch {
+			case repo.Requirement == rule.RequirementOptional:
+			case repo.Requirement == rule.RequirementRequired:
+				add([]string{repo.ID})
+				own[p.ID] = append(own[p.ID], []string{repo.ID})
+			case repo.Group != "":
+				group = append(group, repo.ID)
+				grouped = append(grouped, p.ID)
+			case repo.Requirement == rule.RequirementOneOf:
+				oneOf = append(oneOf, repo.ID)
+			default:
+				implicit = append(implicit, repo.ID)
+			}
+		}
+		add(implicit)
+		add(oneOf)
+		for _, clause := range [][]string{implicit, oneOf} {
+			if len(clause) > 0 {
+				own[p.ID] = append(own[p.ID], clause)
+			}
+		}
+	}
+	add(group)
+	for _, id := range grouped {
+		own[id] = append(own[id], group)
+	}
+
+	if len(clauses) == 0 && len(suggested) > 0 {
+		clauses = append(clauses, suggested)
+	}
+
+	// Every choice of repositories meets the requirements iff it meets the original constraints
+	for choice := 0; choice < 1<<uint(len(universe)-1); choice++ {
+		chosen := make(map[string]bool)
+		for i, id := range universe[:len(universe)-1] {
+			chosen[id] = choice&(1<<uint(i)) != 0
+		}
+
+		expected := true
+		for _, clause := range clauses {
+			expected = expected && hits(clause, chosen)
+		}
+
+		met := true
+		for _, repo := range analyzed.Required {
+			met = met && chosen[repo.ID]
+		}
+		for _, group := range analyzed.OneOf {
+			met = met && hits(ids(group), chosen)
+		}
+
+		if met != expected {
+			t.Fatalf("choice %v meets requirements: %t, meets policies: %t; %s", chosen, met, expected, describe())
+		}
+
+		// Each policy is satisfied iff the choice meets what it calls for
+		var choice []rule.Repository
+		for _, id := range universe[:len(universe)-1] {
+			if chosen[id] {
+				choice = append(choice, rule.Repository{ID: id})
+			}
+		}
+		compliance := analyzed.Check(choice)
+		for _, policies := range []struct {
+			sources   []rule.Source
+			satisfied bool
+		}{{compliance.Satisfied, true}, {compliance.Unsatisfied, false}} {
+			for _, source := range policies.sources {
+				expected := true
+				for _, clause := range own[source.PolicyID] {
+					expected = expected && (hits(clause, chosen) || (len(choice) > 0 && subset([]string{"*"}, clause)))
+				}
+				if policies.satisfied != expected {
+					t.Fatalf("choice %v satisfies policy %s: %t, meets it: %t; %s",
+						chosen, source.PolicyID, policies.satisfied, expected, describe())
+				}
+			}
+		}
+	}
+
+	// Requirements are in simplest form
+	for i, group := range analyzed.OneOf {
+		if len(group) < 2 {
+			t.Fatalf("one-of group with fewer than two members; %s", describe())
+		}
+		for _, repo := range group {
+			if repo.ID == "*" || contains(analyzed.Required, repo.ID) {
+				t.Fatalf("one-of group contains * or a required repository; %s", describe())
+			}
+		}
+		for j, other := range analyzed.OneOf {
+			if i != j && subset(ids(group), ids(other)) {
+				t.Fatalf("one-of group subsumes another; %s", describe())
+			}
+		}
+	}
+
+	// Every repository appears somewhere, and is only optional if not otherwise called for
+	for id := range mentioned {
+		inGroup := false
+		for _, group := range analyzed.OneOf {
+			inGroup = inGroup || contains(group, id)
+		}
+		placed := 0
+		for _, placement := range []bool{contains(analyzed.Required, id), inGroup, contains(analyzed.Optional, id)} {
+			if placement {
+				placed++
+			}
+		}
+		if placed != 1 {
+			t.Fatalf("repository %s is placed %d times; %s", id, placed, describe())
+		}
+	}
+}
+
+func describePolicies(policies []rule.Policy) string {
+	var description []string
+	for _, p := range policies {
+		var repos []string
+		for _, repo := range p.Repositories {
+			repos = append(repos, repo.ID+repo.Requirement+repo.Group)
+		}
+		description = append(description, fmt.Sprintf("%v", repos))
+	}
+	return fmt.Sprintf("%v", description)
+}
+
+func ids(repos []rule.Repository) []string {
+	var ids []string
+	for _, repo := range repos {
+		ids = append(ids, repo.ID)
+	}
+	return ids
+}
+
+func hits(clause []string, chosen map[string]bool) bool {
+	for _, id := range clause {
+		if chosen[id] {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(repos []rule.Repository, id string) bool {
+	for _, repo := range repos {
+		if repo.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func subset(a, b []string) bool {
+	for _, x := range a {
+		found := false
+		for _, y := range b {
+			found = found || x == y
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,144 @@
+package rule
+
+// Compliance describes how well a choice of repositories satisfies deposit requirements
+type Compliance struct {
+	Compliant       bool           `json:"compliant"`            // true if all requirements are met
+	Satisfied       []Source       `json:"satisfied-policies"`   // policies whose requirements are all met
+	Unsatisfied     []Source       `json:"unsatisfied-policies"` // policies with some requirement unmet
+	MissingRequired []Repository   `json:"missing-required"`     // required repositories that weren't chosen
+	UnmetOneOf      [][]Repository `json:"unmet-one-of"`         // one-of groups none of whose members were chosen
+	Unnecessary     []Repository   `json:"unnecessary"`          // chosen repositories that could be dropped
+}
+
+// Check evaluates a choice of repositories against the requirements.  Policies are identified by
+// the sources of the requirements.  If the requirements were analyzed from policies, a policy is
+// satisfied if the choice meets what the policy itself calls for, whether or not the same
+// repositories are called for by other policies too.  Otherwise, a policy is satisfied if every
+// required repository it is a source of is chosen, and some member of every one-of group it is a
+// source of is chosen.  A chosen repository is unnecessary if the requirements would still be met
+// without it (though perhaps not without several unnecessary repositories).  A one-of group
+// containing "*" is met by choosing any repository.
+func (r *Requirements) Check(chosen []Repository) *Compliance {
+	compliance := &Compliance{
+		Satisfied:       []Source{},
+		Unsatisfied:     []Source{},
+		MissingRequired: []Repository{},
+		UnmetOneOf:      [][]Repository{},
+		Unnecessary:     []Repository{},
+	}
+
+	isChosen := make(map[string]bool, len(chosen))
+	for _, repo := range chosen {
+		isChosen[repo.ID] = true
+	}
+
+	unsatisfied := make(map[Source]bool)
+	var policies []Source
+
+	for _, repo := range r.Required {
+		if !isChosen[repo.ID] {
+			compliance.MissingRequired = append(compliance.MissingRequired, repo)
+			markSources(unsatisfied, repo)
+		}
+		policies = mergeSources(policies, repo.Sources)
+	}
+
+	for _, group := range r.OneOf {
+		if !groupMet(group, isChosen, len(chosen) > 0) {
+			compliance.UnmetOneOf = append(compliance.UnmetOneOf, group)
+			for _, member := range group {
+				markSources(unsatisfied, member)
+			}
+		}
+		for _, member := range group {
+			policies = mergeSources(policies, member.Sources)
+		}
+	}
+
+	for _, repo := range r.Optional {
+		policies = mergeSources(policies, repo.Sources)
+	}
+
+	if r.clauses != nil {
+		unsatisfied = r.unsatisfiedPolicies(isChosen, len(chosen) > 0)
+	}
+
+	for _, policy := range policies {
+		if unsatisfied[policy] {
+			compliance.Unsatisfied = append(compliance.Unsatisfied, policy)
+		} else {
+			compliance.Satisfied = append(compliance.Satisfied, policy)
+		}
+	}
+
+	for _, repo := range uniqueRepos(append([]Repository{}, chosen...)) {
+		if isNecessary(r, repo, isChosen) {
+			continue
+		}
+		compliance.Unnecessary = append(compliance.Unnecessary, repo)
+	}
+
+	compliance.Compliant = len(compliance.MissingRequired) == 0 && len(compliance.UnmetOneOf) == 0
+
+	return compliance
+}
+
+// unsatisfiedPolicies determines which policies call for a clause that isn't met by the choice
+func (r *Requirements) unsatisfiedPolicies(isChosen map[string]bool, anyChosen bool) map[Source]bool {
+	unsatisfied := make(map[Source]bool)
+	for source, f := range r.clauses {
+		for _, c := range f {
+			if !groupMet(c, isChosen, anyChosen) {
+				unsatisfied[source] = true
+			}
+		}
+	}
+
+	return unsatisfied
+}
+
+// groupMet determines if a one-of group has a chosen member
+func groupMet(group []Repository, isChosen map[string]bool, anyChosen bool) bool {
+	for _, member := range group {
+		if isChosen[member.ID] || (member.ID == "*" && anyChosen) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNecessary determines if a chosen repository is required, or is the only chosen member of a
+// one-of group
+func isNecessary(r *Requirements, repo Repository, isChosen map[string]bool) bool {
+	if repoListContains(r.Required, repo) {
+		return true
+	}
+
+	for _, group := range r.OneOf {
+		if !repoListContains(group, repo) {
+			continue
+		}
+
+		others := 0
+		for _, member := range group {
+			if member.ID == "*" {
+				// Met by any other chosen repository
+				others += len(isChosen) - 1
+			} else if member.ID != repo.ID && isChosen[member.ID] {
+				others++
+			}
+		}
+		if others == 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// markSources marks the sources of a repository
+func markSources(marked map[Source]bool, repo Repository) {
+	for _, source := range repo.Sources {
+		marked[source] = true
+	}
+}
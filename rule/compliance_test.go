@@ -0,0 +1,168 @@
+package rule_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+func TestCheck(t *testing.T) {
+	p1 := rule.Source{PolicyID: "p1", RuleID: "r1"}
+	p2 := rule.Source{PolicyID: "p2", RuleID: "r2"}
+	p3 := rule.Source{PolicyID: "p3", RuleID: "r3"}
+
+	// a and (b or c), with d optional
+	requirements := &rule.Requirements{
+		Required: []rule.Repository{
+			{ID: "a", Sources: []rule.Source{p1}},
+		},
+		OneOf: [][]rule.Repository{{
+			{ID: "b", Sources: []rule.Source{p2}},
+			{ID: "c", Sources: []rule.Source{p2}},
+		}},
+		Optional: []rule.Repository{
+			{ID: "d", Sources: []rule.Source{p3}},
+		},
+	}
+
+	cases := []struct {
+		testName string
+		chosen   []string
+		expected *rule.Compliance
+	}{{
+		testName: "compliant",
+		chosen:   []string{"a", "c"},
+		expected: &rule.Compliance{
+			Compliant:       true,
+			Satisfied:       []rule.Source{p1, p2, p3},
+			Unsatisfied:     []rule.Source{},
+			MissingRequired: []rule.Repository{},
+			UnmetOneOf:      [][]rule.Repository{},
+			Unnecessary:     []rule.Repository{},
+		},
+	}, {
+		testName: "nothing chosen",
+		expected: &rule.Compliance{
+			Satisfied:       []rule.Source{p3},
+			Unsatisfied:     []rule.Source{p1, p2},
+			MissingRequired: requirements.Required,
+			UnmetOneOf:      requirements.OneOf,
+			Unnecessary:     []rule.Repository{},
+		},
+	}, {
+		testName: "missing one-of",
+		chosen:   []string{"a", "d"},
+		expected: &rule.Compliance{
+			Satisfied:       []rule.Source{p1, p3},
+			Unsatisfied:     []rule.Source{p2},
+			MissingRequired: []rule.Repository{},
+			UnmetOneOf:      requirements.OneOf,
+			Unnecessary:     []rule.Repository{{ID: "d"}},
+		},
+	}, {
+		testName: "redundant",
+		chosen:   []string{"a", "b", "c", "e"},
+		expected: &rule.Compliance{
+			Compliant:       true,
+			Satisfied:       []rule.Source{p1, p2, p3},
+			Unsatisfied:     []rule.Source{},
+			MissingRequired: []rule.Repository{},
+			UnmetOneOf:      [][]rule.Repository{},
+			Unnecessary:     []rule.Repository{{ID: "b"}, {ID: "c"}, {ID: "e"}},
+		},
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			var chosen []rule.Repository
+			for _, id := range c.chosen {
+				chosen = append(chosen, rule.Repository{ID: id})
+			}
+
+			compliance := requirements.Check(chosen)
+			diffs := deep.Equal(compliance, c.expected)
+			if len(diffs) > 0 {
+				t.Fatalf("did not get expected compliance: %s\n%+v", strings.Join(diffs, "\n"), compliance)
+			}
+		})
+	}
+}
+
+// Each policy is checked against what it calls for, even where the analysis merges what policies
+// call for into fewer requirements
+func TestCheckOverlappingPolicies(t *testing.T) {
+	p1 := rule.Source{PolicyID: "p1", RuleID: "r1"}
+	p2 := rule.Source{PolicyID: "p2", RuleID: "r2"}
+
+	policy := func(source rule.Source, repos ...string) rule.Policy {
+		p := rule.Policy{ID: source.PolicyID, RuleID: source.RuleID}
+		for _, id := range repos {
+			p.Repositories = append(p.Repositories, rule.Repository{ID: id})
+		}
+		return p
+	}
+
+	cases := []struct {
+		testName    string
+		policies    []rule.Policy
+		keep        []string
+		chosen      []string
+		satisfied   []rule.Source
+		unsatisfied []rule.Source
+	}{{
+		testName:    "required, and one-of including it",
+		policies:    []rule.Policy{policy(p1, "a"), policy(p2, "a", "b")},
+		chosen:      []string{"b"},
+		satisfied:   []rule.Source{p2},
+		unsatisfied: []rule.Source{p1},
+	}, {
+		testName:    "both met",
+		policies:    []rule.Policy{policy(p1, "a"), policy(p2, "a", "b")},
+		chosen:      []string{"a"},
+		satisfied:   []rule.Source{p1, p2},
+		unsatisfied: []rule.Source{},
+	}, {
+		testName:    "one-of, and one-of subsumed by it",
+		policies:    []rule.Policy{policy(p1, "a", "b"), policy(p2, "a", "b", "c")},
+		chosen:      []string{"c"},
+		satisfied:   []rule.Source{p2},
+		unsatisfied: []rule.Source{p1},
+	}, {
+		testName:    "one-of presumed satisfied outside of PASS",
+		policies:    []rule.Policy{policy(p1, "a"), policy(p2, "a", "c")},
+		keep:        []string{"a"},
+		satisfied:   []rule.Source{p2},
+		unsatisfied: []rule.Source{p1},
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			var chosen []rule.Repository
+			for _, id := range c.chosen {
+				chosen = append(chosen, rule.Repository{ID: id})
+			}
+
+			requirements := rule.AnalyzeRequirements(c.policies)
+			if c.keep != nil {
+				var keep []rule.Repository
+				for _, id := range c.keep {
+					keep = append(keep, rule.Repository{ID: id})
+				}
+				requirements, _ = requirements.Keep(keep)
+			}
+
+			compliance := requirements.Check(chosen)
+
+			if diffs := deep.Equal(compliance.Satisfied, c.satisfied); len(diffs) > 0 {
+				t.Fatalf("did not get expected satisfied policies: %s", strings.Join(diffs, "\n"))
+			}
+			if diffs := deep.Equal(compliance.Unsatisfied, c.unsatisfied); len(diffs) > 0 {
+				t.Fatalf("did not get expected unsatisfied policies: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
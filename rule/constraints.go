@@ -0,0 +1,57 @@
+package rule
+
+// Constraints restrict how a deposit into a repository must be made in order to satisfy
+// a policy.  Absent constraints (nil values) are unconstrained.
+type Constraints struct {
+	MaxEmbargoMonths    *int     `json:"max-embargo-months,omitempty"`    // longest permissible embargo
+	DepositDeadlineDays *int     `json:"deposit-deadline-days,omitempty"` // days after publication by which to deposit
+	ManuscriptVersions  []string `json:"manuscript-versions"`             // acceptable manuscript versions, nil if any
+}
+
+// Merge combines two sets of constraints into one that satisfies both, so that the strictest
+// constraint wins:  the shortest embargo and deadline, and only the manuscript versions acceptable
+// to both.  If no manuscript version is acceptable to both, the result has an empty (not nil) list
+// of versions.  Either set of constraints may be nil.
+func (c *Constraints) Merge(other *Constraints) *Constraints {
+	if c == nil {
+		return other
+	}
+	if other == nil {
+		return c
+	}
+
+	return &Constraints{
+		MaxEmbargoMonths:    minOf(c.MaxEmbargoMonths, other.MaxEmbargoMonths),
+		DepositDeadlineDays: minOf(c.DepositDeadlineDays, other.DepositDeadlineDays),
+		ManuscriptVersions:  intersection(c.ManuscriptVersions, other.ManuscriptVersions),
+	}
+}
+
+func minOf(a, b *int) *int {
+	if a == nil || (b != nil && *b < *a) {
+		return b
+	}
+	return a
+}
+
+// intersection of two lists, where a nil list contains everything
+func intersection(a, b []string) []string {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	common := []string{}
+	for _, val := range a {
+		for _, other := range b {
+			if val == other {
+				common = append(common, val)
+				break
+			}
+		}
+	}
+
+	return common
+}
@@ -3,6 +3,7 @@ package rule
 import (
 	"encoding/json"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -11,6 +12,7 @@ import (
 const (
 	SubmissionVariable = "submission" // ${submission}
 	HeaderVariable     = "header"     // ${header}
+	NowVariable        = "now"        // ${now}, the time of evaluation as an RFC 3339 timestamp
 )
 
 // PassEntityFetcher retrieves the JSON-LD content at the given url, and
@@ -33,14 +35,27 @@ type resolvedObject struct {
 // Context establishes a rule evaluation/resolution context.
 type Context struct {
 	SubmissionURI string
+	Submission    map[string]interface{} // content of the submission.  If nil, it is fetched from SubmissionURI
 	Headers       map[string][]string
 	PassClient    PassEntityFetcher
+	Now           time.Time              // time of evaluation.  If zero, the current time is used
+	Trace         *Trace                 // records the evaluation of rules in the context, if not nil
+	Matched       func(Policy)           // called with each policy the rules produce, if not nil
 	values        map[string]interface{} // values that have been already resolved
 }
 
 // Resolve resolves a variable of the form ${a.b.c.d}, returning
 // a list of strings.
 func (c *Context) Resolve(vari string) ([]string, error) {
+	values, err := c.resolve(vari)
+	if err == nil && IsVariable(vari) {
+		c.Trace.variable(vari, values)
+	}
+
+	return values, err
+}
+
+func (c *Context) resolve(vari string) ([]string, error) {
 
 	c.init()
 
@@ -90,7 +105,13 @@ func (c *Context) Resolve(vari string) ([]string, error) {
 
 }
 
+// Pin returns a context in which the given variable has the given value.  The objects leading
+// to that value are pinned as well:  pinning ${submission.grants.primaryFunder.policy} narrows
+// ${submission.grants.primaryFunder} to the funders having that policy, and ${submission.grants}
+// to the grants having those funders.
 func (c *Context) Pin(variable, value string) VariablePinner {
+	c.init()
+
 	parsed, ok := toVariable(variable)
 	if !ok {
 		return c
@@ -106,16 +127,58 @@ func (c *Context) Pin(variable, value string) VariablePinner {
 	pinnedValues[parsed.fullName] = value
 	pinnedValues[segments[len(segments)-1]] = value
 
+	// Walk up the chain of variable segments, keeping only the objects that lead to the pinned values
+	pinned := []string{value}
+walk:
+	for i := len(segments) - 1; i > 0; i-- {
+		segmentName := strings.Join(segments[:i], ".")
+
+		switch objects := pinnedValues[segmentName].(type) {
+		case resolvedObject:
+			// A single object necessarily leads to the pinned values
+			pinned = []string{objects.src}
+		case []resolvedObject:
+			var narrowed []resolvedObject
+			var sources []string
+			for _, obj := range objects {
+				if containsAny(obj.object[segments[i]], pinned) {
+					narrowed = append(narrowed, obj)
+					sources = append(sources, obj.src)
+				}
+			}
+
+			pinnedValues[segmentName] = narrowed
+			pinnedValues[segments[i-1]] = narrowed
+			pinned = sources
+
+			// Values previously derived from the un-narrowed objects are no longer valid
+			for k := range pinnedValues {
+				derived := strings.HasPrefix(k, segmentName+".") || strings.HasPrefix(k, segments[i-1]+".")
+				if derived && !strings.HasPrefix(parsed.fullName+".", k+".") {
+					delete(pinnedValues, k)
+				}
+			}
+		default:
+			// Not resolved into objects, so there is nothing to narrow
+			break walk
+		}
+	}
+
 	return &Context{
 		SubmissionURI: c.SubmissionURI,
+		Submission:    c.Submission,
 		Headers:       c.Headers,
 		PassClient:    c.PassClient,
+		Now:           c.Now,
+		Trace:         c.Trace,
+		Matched:       c.Matched,
 		values:        pinnedValues,
 	}
 
 }
 
-// Set the ${submission} and ${header} values, if not set already
+// Set the ${submission}, ${header}, and ${now} values, if not set already.  If the content of
+// the submission is given, ${submission} is that object, rather than a URI to fetch it from.
 func (c *Context) init() {
 
 	// if the values map is already initialized, we're done
@@ -123,8 +186,17 @@ func (c *Context) init() {
 		return
 	}
 
+	if c.Now.IsZero() {
+		c.Now = time.Now()
+	}
+
 	c.values = map[string]interface{}{
 		SubmissionVariable: c.SubmissionURI,
+		NowVariable:        c.Now.Format(time.RFC3339),
+	}
+
+	if c.Submission != nil {
+		c.values[SubmissionVariable] = resolvedObject{src: c.SubmissionURI, object: c.Submission}
 	}
 
 	headers := make(map[string]interface{}, len(c.Headers))
@@ -303,6 +375,27 @@ func (c *Context) resolveToObjects(v variable, vals []string) error {
 	return nil
 }
 
+// containsAny determines if a value (a string, or list of strings) contains any of the given strings
+func containsAny(value interface{}, vals []string) bool {
+	var items []interface{}
+	switch v := value.(type) {
+	case string:
+		items = []interface{}{v}
+	case []interface{}:
+		items = v
+	}
+
+	for _, item := range items {
+		for _, val := range vals {
+			if item == val {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
 func uniq(vals []string) []string {
 	uniqueVals := []string{}
 	encountered := make(map[string]bool, len(vals))
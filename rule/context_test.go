@@ -312,6 +312,64 @@ func TestContextMultipleResolve(t *testing.T) {
 	}
 }
 
+func TestContextInlineSubmission(t *testing.T) {
+
+	// The submission isn't stored, but the grants it links to are
+	fetcher := testFetcher(map[string]string{
+		"http://example.org/grant": `{
+			"primaryFunder": "http://example.org/funder"
+		}`,
+	})
+
+	cases := []struct {
+		testName      string
+		submissionURI string
+		varName       string
+		expectedValue []string
+	}{{
+		testName:      "submission",
+		submissionURI: "http://example.org/draft",
+		varName:       "${submission}",
+		expectedValue: []string{"http://example.org/draft"},
+	}, {
+		testName:      "unsaved submission",
+		varName:       "${submission}",
+		expectedValue: []string{""},
+	}, {
+		testName:      "submissionProperty",
+		varName:       "${submission.title}",
+		expectedValue: []string{"Draft"},
+	}, {
+		testName:      "linked",
+		varName:       "${submission.grants.primaryFunder}",
+		expectedValue: []string{"http://example.org/funder"},
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			cxt := rule.Context{
+				SubmissionURI: c.submissionURI,
+				Submission: map[string]interface{}{
+					"title":  "Draft",
+					"grants": []interface{}{"http://example.org/grant"},
+				},
+				PassClient: fetcher,
+			}
+
+			vals, err := cxt.Resolve(c.varName)
+			if err != nil {
+				t.Fatalf("Error resolving variable %s: %+v", c.varName, err)
+			}
+
+			diffs := deep.Equal(vals, c.expectedValue)
+			if len(diffs) != 0 {
+				t.Fatalf("Found differences in expected values: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
 func TestContextErrors(t *testing.T) {
 	submissionURI := "http://example.org/submission"
 
@@ -0,0 +1,160 @@
+package rule
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	refKey                = "$ref"
+	conditionRefPrefix    = "#/definitions/conditions/"
+	repositoriesRefPrefix = "#/definitions/repositories/"
+)
+
+// Definitions contains named conditions and repository lists, which policy rules
+// may reference as {"$ref": "#/definitions/conditions/NAME"} or
+// {"$ref": "#/definitions/repositories/NAME"}.  References are replaced with what
+// they refer to when a rules document is validated, so resolved policy rules never
+// contain references.
+type Definitions struct {
+	Conditions   map[string]Condition    `json:"conditions,omitempty"`
+	Repositories map[string][]Repository `json:"repositories,omitempty"`
+}
+
+// inherit adds the definitions of an included document.  Names already defined take precedence.
+func (d *Definitions) inherit(included Definitions) {
+	for name, cond := range included.Conditions {
+		if _, ok := d.Conditions[name]; !ok {
+			if d.Conditions == nil {
+				d.Conditions = make(map[string]Condition)
+			}
+			d.Conditions[name] = cond
+		}
+	}
+
+	for name, repos := range included.Repositories {
+		if _, ok := d.Repositories[name]; !ok {
+			if d.Repositories == nil {
+				d.Repositories = make(map[string][]Repository)
+			}
+			d.Repositories[name] = repos
+		}
+	}
+}
+
+// resolve replaces any references in a policy rule with the definitions they refer to
+func (d Definitions) resolve(p Policy) (Policy, error) {
+	var err error
+
+	p.Conditions, err = d.resolveConditions(p.Conditions, nil)
+	if err != nil {
+		return p, errors.Wrapf(err, "could not resolve conditions")
+	}
+
+	p.Repositories, err = d.resolveRepositories(p.Repositories)
+	if err != nil {
+		return p, errors.Wrapf(err, "could not resolve repositories")
+	}
+
+	return p, nil
+}
+
+// resolveConditions resolves references in a list of conditions.  refs contains the names of
+// the definitions being resolved, in order to detect cycles
+func (d Definitions) resolveConditions(conditions []Condition, refs []string) ([]Condition, error) {
+	if len(conditions) == 0 {
+		return conditions, nil
+	}
+
+	resolved := make([]Condition, 0, len(conditions))
+	for _, cond := range conditions {
+		r, err := d.resolveCondition(cond, refs)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, r)
+	}
+
+	return resolved, nil
+}
+
+func (d Definitions) resolveCondition(cond Condition, refs []string) (Condition, error) {
+
+	// A reference stands in for the entire condition
+	if ref, ok := cond[refKey]; ok {
+		refString, ok := ref.(string)
+		if !ok {
+			return nil, errors.Errorf("expecting a string reference, instead got %T", ref)
+		}
+
+		name := strings.TrimPrefix(refString, conditionRefPrefix)
+		for _, seen := range refs {
+			if seen == name {
+				return nil, errors.Errorf("reference cycle: %s", strings.Join(append(refs, name), " -> "))
+			}
+		}
+
+		def, ok := d.Conditions[name]
+		if !ok {
+			return nil, errors.Errorf("undefined condition %s", refString)
+		}
+
+		return d.resolveCondition(def, append(refs, name))
+	}
+
+	// Otherwise, references may appear in the lists of conditions within anyOf and noneOf
+	resolved := make(Condition, len(cond))
+	for key, val := range cond {
+		list, ok := val.([]interface{})
+		if !ok {
+			resolved[key] = val
+			continue
+		}
+
+		items := make([]interface{}, 0, len(list))
+		for _, item := range list {
+			obj, ok := item.(map[string]interface{})
+			if !ok {
+				items = append(items, item)
+				continue
+			}
+
+			r, err := d.resolveCondition(obj, refs)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not resolve '%s'", key)
+			}
+			items = append(items, map[string]interface{}(r))
+		}
+		resolved[key] = items
+	}
+
+	return resolved, nil
+}
+
+// resolveRepositories expands references to repository lists, and resolves references
+// in the conditions of each repository
+func (d Definitions) resolveRepositories(repos []Repository) ([]Repository, error) {
+	resolved := make([]Repository, 0, len(repos))
+	for _, repo := range repos {
+		list := []Repository{repo}
+		if repo.Ref != "" {
+			def, ok := d.Repositories[strings.TrimPrefix(repo.Ref, repositoriesRefPrefix)]
+			if !ok {
+				return nil, errors.Errorf("undefined repositories %s", repo.Ref)
+			}
+			list = def
+		}
+
+		for _, r := range list {
+			var err error
+			r.Conditions, err = d.resolveConditions(r.Conditions, nil)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not resolve conditions of repository %s", r.ID)
+			}
+			resolved = append(resolved, r)
+		}
+	}
+
+	return resolved, nil
+}
@@ -0,0 +1,164 @@
+package rule_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+func TestDefinitions(t *testing.T) {
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"definitions": {
+			"conditions": {
+				"member": {
+					"endsWith": {"@example.org": "${header.Eppn}"}
+				},
+				"faculty": {
+					"contains": {"FACULTY": "${header.Affiliation}"}
+				},
+				"non-faculty": {
+					"noneOf": [
+						{"$ref": "#/definitions/conditions/faculty"}
+					]
+				}
+			},
+			"repositories": {
+				"ir": [
+					{"repository-id": "a", "selected": true},
+					{"repository-id": "b"}
+				]
+			}
+		},
+		"policy-rules": [
+			{
+				"policy-id": "policy",
+				"type": "institution",
+				"conditions": [
+					{"$ref": "#/definitions/conditions/member"},
+					{"$ref": "#/definitions/conditions/non-faculty"}
+				],
+				"repositories": [
+					{"$ref": "#/definitions/repositories/ir"},
+					{"repository-id": "*"}
+				]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	diffs := deep.Equal(rules.Policies[0].Repositories, []rule.Repository{
+		{ID: "a", Selected: true},
+		{ID: "b"},
+		{ID: "*"},
+	})
+	if len(diffs) > 0 {
+		t.Fatalf("Found differences in expected repositories: %s", strings.Join(diffs, "\n"))
+	}
+
+	cases := []struct {
+		testName string
+		headers  map[string][]string
+		expected int
+	}{{
+		testName: "non-faculty member",
+		headers: map[string][]string{
+			"Eppn":        {"someone@example.org"},
+			"Affiliation": {"STAFF"},
+		},
+		expected: 1,
+	}, {
+		testName: "faculty member",
+		headers: map[string][]string{
+			"Eppn":        {"someone@example.org"},
+			"Affiliation": {"FACULTY"},
+		},
+		expected: 0,
+	}, {
+		testName: "non-member",
+		headers: map[string][]string{
+			"Eppn":        {"someone@example.com"},
+			"Affiliation": {"STAFF"},
+		},
+		expected: 0,
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			policies, err := rules.Resolve(&rule.Context{Headers: c.headers})
+			if err != nil {
+				t.Fatalf("Could not resolve policies: %+v", err)
+			}
+
+			if len(policies) != c.expected {
+				t.Fatalf("Expected %d policies, got %d", c.expected, len(policies))
+			}
+		})
+	}
+}
+
+func TestBadDefinitions(t *testing.T) {
+	cases := map[string]string{
+		"undefinedCondition": `{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"conditions": [{"$ref": "#/definitions/conditions/nope"}],
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`,
+		"undefinedRepositories": `{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"repositories": [{"$ref": "#/definitions/repositories/nope"}]
+			}]
+		}`,
+		"cycle": `{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"definitions": {
+				"conditions": {
+					"one": {"anyOf": [{"$ref": "#/definitions/conditions/two"}]},
+					"two": {"noneOf": [{"$ref": "#/definitions/conditions/one"}]}
+				}
+			},
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"conditions": [{"$ref": "#/definitions/conditions/one"}],
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`,
+		"refToWrongKind": `{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"definitions": {
+				"repositories": {
+					"ir": [{"repository-id": "a"}]
+				}
+			},
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"conditions": [{"$ref": "#/definitions/repositories/ir"}],
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`,
+	}
+
+	for name, doc := range cases {
+		doc := doc
+		t.Run(name, func(t *testing.T) {
+			_, err := rule.Validate([]byte(doc))
+			if err == nil {
+				t.Fatalf("Validation should have failed!")
+			}
+		})
+	}
+}
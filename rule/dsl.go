@@ -4,25 +4,70 @@ import (
 	"github.com/pkg/errors"
 )
 
+// DefaultPolicyTypes is the vocabulary of policy types for rules documents that don't declare one
+var DefaultPolicyTypes = []string{"funder", "institution"}
+
 // DSL encapsulates to a policy rules document
 type DSL struct {
-	Schema   string   `json:"$schema"`
-	Policies []Policy `json:"policy-rules"`
+	Schema        string      `json:"$schema"`
+	Include       []string    `json:"include,omitempty"`        // paths of other rules documents whose rules precede these
+	Types         []string    `json:"policy-types,omitempty"`   // ordered vocabulary of policy types
+	ReferenceDate string      `json:"reference-date,omitempty"` // default reference date for the rules' effective dates
+	Selection     *Selection  `json:"selection,omitempty"`      // how to decide which repositories are selected by default
+	Definitions   Definitions `json:"definitions"`              // named conditions and repositories, for use in rules
+	Policies      []Policy    `json:"policy-rules"`
 }
 
 type PolicyResolver interface {
 	Resolve(variables VariablePinner) ([]Policy, error)
 }
 
+// PolicyTypeVocabulary provides the ordered vocabulary of policy types used by a set of rules,
+// e.g. for grouping and ordering policies by type.
+type PolicyTypeVocabulary interface {
+	PolicyTypes() []string
+}
+
+// RequirementsAnalyzer analyzes the repository requirements of a list of policies, as
+// prescribed by a set of rules
+type RequirementsAnalyzer interface {
+	AnalyzeRequirements(policies []Policy) *Requirements
+}
+
+// AnalyzeRequirements analyzes the repository requirements of a list of policies, like
+// AnalyzeRequirements, but selects repositories according to the rules document's selection
+func (d *DSL) AnalyzeRequirements(policies []Policy) *Requirements {
+	return analyzeWithSelection(policies, d.Selection)
+}
+
+// PolicyTypes returns the ordered vocabulary of policy types of the rules document
+func (d *DSL) PolicyTypes() []string {
+	if len(d.Types) == 0 {
+		return DefaultPolicyTypes
+	}
+	return d.Types
+}
+
+// Resolve evaluates the policy rules, returning the policies they produce.  If the rules are
+// evaluated in a Context with a Matched function, it is called with each of the policies.
 func (d *DSL) Resolve(variables VariablePinner) ([]Policy, error) {
 	var policies []Policy
 	for _, policy := range d.Policies {
 		resolved, err := policy.Resolve(variables)
-		if err != nil {
-			return policies, errors.Wrapf(err, "could not resolve policy rule")
+		if err != nil && policy.source != "" {
+			return policies, errors.Wrapf(err, "could not resolve policy rule %s from %s", policy.RuleID, policy.source)
+		} else if err != nil {
+			return policies, errors.Wrapf(err, "could not resolve policy rule %s", policy.RuleID)
 		}
 		policies = append(policies, resolved...)
 	}
 
-	return uniquePolicies(policies), nil
+	policies = uniquePolicies(policies)
+	if context, ok := variables.(*Context); ok && context.Matched != nil {
+		for _, p := range policies {
+			context.Matched(p)
+		}
+	}
+
+	return policies, nil
 }
@@ -113,3 +113,79 @@ func TestDSL(t *testing.T) {
 		t.Fatalf("Found differences in expected repositories: %s", strings.Join(diffs, "\n"))
 	}
 }
+
+// Errors identify the rule that could not be resolved
+func TestDSLResolveError(t *testing.T) {
+	dsl, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-rules": [
+			{
+				"policy-id": "http://example.org/policy",
+				"type": "institution",
+				"repositories": [{"repository-id": "http://example.org/repository"}]
+			},
+			{
+				"rule-id": "nih",
+				"policy-id": "${submission.grants.primaryFunder.policy}",
+				"type": "funder",
+				"repositories": [{"repository-id": "${policy.repositories}"}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("rules failed validation %+v", err)
+	}
+
+	_, err = dsl.Resolve(&rule.Context{
+		SubmissionURI: "http://example.org/submission",
+		PassClient:    errFetcher{},
+	})
+	if err == nil {
+		t.Fatalf("Resolving policies should have failed!")
+	}
+
+	if !strings.Contains(err.Error(), "could not resolve policy rule nih") {
+		t.Fatalf("Expected error to identify the rule, got %s", err)
+	}
+}
+
+// Each policy the rules produce is reported to the context
+func TestDSLResolveMatched(t *testing.T) {
+	dsl, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-rules": [
+			{
+				"rule-id": "both",
+				"policy-id": "${header.Policies}",
+				"type": "institution",
+				"repositories": [{"repository-id": "http://example.org/repository"}]
+			},
+			{
+				"rule-id": "none",
+				"policy-id": "http://example.org/never",
+				"type": "institution",
+				"conditions": [{"equals": {"yes": "no"}}],
+				"repositories": [{"repository-id": "http://example.org/repository"}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("rules failed validation %+v", err)
+	}
+
+	var matched []string
+	_, err = dsl.Resolve(&rule.Context{
+		Headers: map[string][]string{"Policies": {"http://example.org/a", "http://example.org/b"}},
+		Matched: func(p rule.Policy) {
+			matched = append(matched, p.RuleID+" "+p.ID)
+		},
+	})
+	if err != nil {
+		t.Fatalf("Error resolving policies: %+v", err)
+	}
+
+	diffs := deep.Equal(matched, []string{"both http://example.org/a", "both http://example.org/b"})
+	if len(diffs) > 0 {
+		t.Fatalf("Found differences in expected matches: %s", strings.Join(diffs, "\n"))
+	}
+}
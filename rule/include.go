@@ -0,0 +1,230 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ValidateFile reads the policy rules document at the given path, and
+// validates and parses it along with any documents it includes.  Included
+// paths are relative to the including document.
+func ValidateFile(path string) (*DSL, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s", path)
+	}
+
+	return (&loader{root: filepath.Dir(path)}).load(path, content)
+}
+
+// loader validates and parses rules documents, following includes.
+type loader struct {
+	root   string          // directory of the root document, or empty for the working directory
+	stack  []string        // absolute paths of the documents currently being loaded
+	loaded map[string]*DSL // documents loaded so far, by absolute path
+}
+
+// load validates and parses a rules document found at the given path.  The path
+// may be empty if the document did not come from a file, in which case includes
+// are relative to the working directory.  Rules from included documents precede
+// the document's own rules, and definitions from included documents are available
+// to the document's own rules.
+func (l *loader) load(path string, rulesDoc []byte) (*DSL, error) {
+	var doc locator
+	if isYAML(path, rulesDoc) {
+		var err error
+		if rulesDoc, doc.yaml, err = yamlToJSON(rulesDoc); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateSchema(rulesDoc); err != nil {
+		return nil, doc.locateErrors(err)
+	}
+
+	// Serialize just to defend against programmer error
+	rules := DSL{}
+	decoder := json.NewDecoder(bytes.NewReader(rulesDoc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, errors.Wrapf(err, "could not decode rules doc")
+	}
+
+	if l.loaded == nil {
+		l.loaded = make(map[string]*DSL)
+	}
+
+	if path != "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not determine location of %s", path)
+		}
+		l.stack = append(l.stack, abs)
+		l.loaded[abs] = &rules
+		defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+	}
+
+	var policies []Policy
+	var includedTypes []string
+	for _, include := range rules.Include {
+		included, duplicate, err := l.include(path, include)
+		if err != nil {
+			return nil, err
+		}
+
+		rules.Definitions.inherit(included.Definitions)
+		includedTypes = append(includedTypes, included.PolicyTypes()...)
+		if rules.Selection == nil {
+			rules.Selection = included.Selection
+		}
+		if !duplicate {
+			policies = append(policies, included.Policies...)
+		}
+	}
+
+	// Without a declared vocabulary, any policy types of included documents are
+	// known in addition to the default ones
+	if len(rules.Types) == 0 && len(includedTypes) > 0 {
+		rules.Types = uniq(append(append([]string{}, DefaultPolicyTypes...), includedTypes...))
+	}
+
+	if err := validateTypes(rules.PolicyTypes(), policies, doc); err != nil {
+		return nil, err
+	}
+	if err := validateTypes(rules.PolicyTypes(), rules.Policies, doc); err != nil {
+		return nil, err
+	}
+	if err := rules.Selection.validate(rules.PolicyTypes()); err != nil {
+		return nil, errors.Wrap(err, doc.locate("/selection"))
+	}
+
+	for i := range rules.Policies {
+		resolved, err := rules.Definitions.resolve(rules.Policies[i])
+		if err != nil {
+			return nil, errors.Wrap(err, doc.locate(fmt.Sprintf("/policy-rules/%d", i)))
+		}
+
+		if resolved.ReferenceDate == "" {
+			resolved.ReferenceDate = rules.ReferenceDate
+		}
+
+		if err = resolved.validateEffectiveDates(); err != nil {
+			return nil, errors.Wrap(err, doc.locate(fmt.Sprintf("/policy-rules/%d", i)))
+		}
+
+		if resolved.RuleID == "" {
+			resolved.RuleID = l.generatedRuleID(path, i)
+		}
+
+		resolved.source = path
+		rules.Policies[i] = resolved
+	}
+
+	rules.Policies = append(policies, rules.Policies...)
+
+	if err := validateRuleIDs(rules.Policies); err != nil {
+		return nil, err
+	}
+
+	return &rules, nil
+}
+
+// include loads a document included from the document at the given path.  If the document
+// has already been loaded via some other include, it is returned as a duplicate.
+func (l *loader) include(from, include string) (included *DSL, duplicate bool, err error) {
+	path := include
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(from), include)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not determine location of %s", path)
+	}
+
+	for _, loading := range l.stack {
+		if loading == abs {
+			return nil, false, errors.Errorf("include cycle: %s", strings.Join(append(l.stack, abs), " -> "))
+		}
+	}
+
+	if loaded, ok := l.loaded[abs]; ok {
+		return loaded, true, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "could not read %s included from %s", include, describeSource(from))
+	}
+
+	included, err = l.load(path, content)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "invalid rules in %s included from %s", include, describeSource(from))
+	}
+
+	return included, false, nil
+}
+
+// generatedRuleID identifies a rule without a rule-id by its position in the document at the
+// given path.  The path is relative to the root document, e.g. shared/funders.json#/policy-rules/0,
+// so that documents of the same name in different directories are told apart.
+func (l *loader) generatedRuleID(path string, i int) string {
+	if path == "" {
+		return fmt.Sprintf("#/policy-rules/%d", i)
+	}
+	if rel, err := filepath.Rel(l.root, path); err == nil {
+		path = rel
+	}
+	return fmt.Sprintf("%s#/policy-rules/%d", filepath.ToSlash(path), i)
+}
+
+// validateRuleIDs verifies that no two policy rules have the same rule ID
+func validateRuleIDs(policies []Policy) error {
+	sources := make(map[string]string, len(policies))
+	for _, p := range policies {
+		if source, ok := sources[p.RuleID]; ok {
+			return errors.Errorf("rule-id %s is used by more than one rule, in %s and %s",
+				p.RuleID, describeSource(source), describeSource(p.source))
+		}
+		sources[p.RuleID] = p.source
+	}
+
+	return nil
+}
+
+func describeSource(path string) string {
+	if path == "" {
+		return "rules doc"
+	}
+	return path
+}
+
+// validateTypes verifies that the type of each policy rule is in the given vocabulary.  Rules
+// without a known source are located in the given document.
+func validateTypes(vocabulary []string, policies []Policy, doc locator) error {
+	known := make(map[string]bool, len(vocabulary))
+	for _, t := range vocabulary {
+		known[t] = true
+	}
+
+	for i, p := range policies {
+		if known[p.Type] {
+			continue
+		}
+
+		err := errors.Errorf("policy type '%s' is not one of the known types: %s",
+			p.Type, strings.Join(vocabulary, ", "))
+		if p.source != "" {
+			return errors.Wrapf(err, "rule %s in %s", p.RuleID, p.source)
+		}
+		return errors.Wrap(err, doc.locate(fmt.Sprintf("/policy-rules/%d", i)))
+	}
+
+	return nil
+}
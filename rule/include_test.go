@@ -0,0 +1,136 @@
+package rule_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+// Included rules precede the including document's rules, and documents included
+// more than once contribute their rules only once.
+func TestValidateFileIncludes(t *testing.T) {
+	rules, err := rule.ValidateFile("testdata/include/root.json")
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	var ids []string
+	for _, p := range rules.Policies {
+		ids = append(ids, p.ID)
+	}
+
+	diffs := deep.Equal(ids, []string{"policies/common", "policies/funder", "policies/root"})
+	if len(diffs) > 0 {
+		t.Fatalf("Found differences in expected policies: %s", strings.Join(diffs, "\n"))
+	}
+
+	// Definitions from included documents are available to the including document
+	diffs = deep.Equal(rules.Policies[2].Repositories, []rule.Repository{
+		{ID: "repositories/root"},
+		{ID: "repositories/common"},
+	})
+	if len(diffs) > 0 {
+		t.Fatalf("Found differences in expected repositories: %s", strings.Join(diffs, "\n"))
+	}
+}
+
+// Rules without a rule-id are identified by their position in their document
+func TestGeneratedRuleIDs(t *testing.T) {
+	rules, err := rule.ValidateFile("testdata/include/root.json")
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	var ids []string
+	for _, p := range rules.Policies {
+		ids = append(ids, p.RuleID)
+	}
+
+	diffs := deep.Equal(ids, []string{
+		"shared/common.json#/policy-rules/0",
+		"shared/funders.json#/policy-rules/0",
+		"root.json#/policy-rules/0",
+	})
+	if len(diffs) > 0 {
+		t.Fatalf("Found differences in expected rule IDs: %s", strings.Join(diffs, "\n"))
+	}
+}
+
+// Documents of the same name in different directories generate distinct rule IDs
+func TestGeneratedRuleIDsSameName(t *testing.T) {
+	rules, err := rule.ValidateFile("testdata/include/same_name/root.json")
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	var ids []string
+	for _, p := range rules.Policies {
+		ids = append(ids, p.RuleID)
+	}
+
+	diffs := deep.Equal(ids, []string{
+		"a/funders.json#/policy-rules/0",
+		"b/funders.json#/policy-rules/0",
+	})
+	if len(diffs) > 0 {
+		t.Fatalf("Found differences in expected rule IDs: %s", strings.Join(diffs, "\n"))
+	}
+}
+
+// Includes in a document that didn't come from a file are relative to the working directory
+func TestValidateIncludes(t *testing.T) {
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"include": ["testdata/include/shared/common.json"],
+		"policy-rules": []
+	}`))
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	if len(rules.Policies) != 1 || rules.Policies[0].ID != "policies/common" {
+		t.Fatalf("Did not get expected policies: %+v", rules.Policies)
+	}
+}
+
+func TestValidateFileBadIncludes(t *testing.T) {
+	cases := []struct {
+		file        string
+		errContains string
+	}{
+		{"testdata/include/cycle_a.json", "include cycle"},
+		{"testdata/include/missing.json", "does-not-exist.json included from testdata/include/missing.json"},
+		{"testdata/include/invalid.json", "../bad.json included from testdata/include/invalid.json"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.file, func(t *testing.T) {
+			_, err := rule.ValidateFile(c.file)
+			if err == nil {
+				t.Fatalf("Validation should have failed!")
+			}
+
+			if !strings.Contains(err.Error(), c.errContains) {
+				t.Fatalf("Expected error to mention '%s', got %s", c.errContains, err)
+			}
+		})
+	}
+}
+
+// The shipped deployment configurations should all validate
+func TestValidateFilePolicies(t *testing.T) {
+	files, _ := ioutil.ReadDir("../policies")
+	for _, f := range files {
+		file := "../policies/" + f.Name()
+		t.Run(file, func(t *testing.T) {
+			_, err := rule.ValidateFile(file)
+			if err != nil {
+				t.Fatalf("Validation failed: %+v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+
+	"github.com/pkg/errors"
+)
+
+// migration upgrades a parsed rules document from one schema to the next.
+type migration struct {
+	to      string
+	migrate func(doc map[string]interface{}) error // nil if only $schema needs to change
+}
+
+// migrations are keyed by the schema they migrate from.  Following the chain
+// from any supported schema must end at LatestSchema.
+var migrations = map[string]migration{
+	// 2.0 is a superset of 1.0, so only the $schema declaration changes
+	SchemaV1: {to: SchemaV2},
+}
+
+// Migrate rewrites a serialized policy rules document so that it conforms to
+// LatestSchema.  The document must be valid with respect to the schema it
+// declares.  Migration is lossless:  every rule in the source document has an
+// equivalent in the result.  Documents already at the latest schema are
+// returned unchanged.  Only JSON documents can be migrated, since migrating
+// YAML would lose its comments.
+func Migrate(rulesDoc []byte) ([]byte, error) {
+	if isYAML("", rulesDoc) {
+		return nil, errors.New("cannot migrate a YAML rules doc, only JSON")
+	}
+
+	if err := validateSchema(rulesDoc); err != nil {
+		return nil, errors.Wrapf(err, "cannot migrate an invalid rules doc")
+	}
+
+	schema, _ := declaredSchema(rulesDoc)
+	if schema == LatestSchema {
+		return rulesDoc, nil
+	}
+
+	// If only $schema needs to change, it is replaced in place, keeping the rest of the
+	// document as its curators wrote it
+	if schemaOnly(schema) {
+		if migrated, ok := replaceSchema(rulesDoc, schema, LatestSchema); ok {
+			return validateMigrated(migrated)
+		}
+	}
+
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(rulesDoc, &doc); err != nil {
+		return nil, errors.Wrapf(err, "could not parse rules doc")
+	}
+
+	for schema != LatestSchema {
+		m, ok := migrations[schema]
+		if !ok {
+			return nil, errors.Errorf("no migration from schema %s", schema)
+		}
+
+		if m.migrate != nil {
+			if err := m.migrate(doc); err != nil {
+				return nil, errors.Wrapf(err, "could not migrate from schema %s to %s", schema, m.to)
+			}
+		}
+
+		doc["$schema"] = m.to
+		schema = m.to
+	}
+
+	var migrated bytes.Buffer
+	encoder := json.NewEncoder(&migrated)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "    ")
+	if err := encoder.Encode(doc); err != nil {
+		return nil, errors.Wrapf(err, "could not serialize migrated rules doc")
+	}
+
+	return validateMigrated(migrated.Bytes())
+}
+
+// schemaOnly determines if migrating from the given schema to LatestSchema only changes $schema
+func schemaOnly(schema string) bool {
+	for schema != LatestSchema {
+		m, ok := migrations[schema]
+		if !ok || m.migrate != nil {
+			return false
+		}
+		schema = m.to
+	}
+	return true
+}
+
+// replaceSchema replaces the value of the $schema declaration of a serialized rules document.
+// It fails if the declaration isn't written as expected, e.g. if the URI has escaped characters.
+func replaceSchema(rulesDoc []byte, from, to string) ([]byte, bool) {
+	declaration := regexp.MustCompile(`"\$schema"\s*:\s*("` + regexp.QuoteMeta(from) + `")`)
+
+	match := declaration.FindSubmatchIndex(rulesDoc)
+	if match == nil {
+		return nil, false
+	}
+
+	replacement, _ := json.Marshal(to)
+
+	var migrated bytes.Buffer
+	migrated.Write(rulesDoc[:match[2]])
+	migrated.Write(replacement)
+	migrated.Write(rulesDoc[match[3]:])
+
+	return migrated.Bytes(), true
+}
+
+// validateMigrated defends against a faulty migration
+func validateMigrated(migrated []byte) ([]byte, error) {
+	if err := validateSchema(migrated); err != nil {
+		return nil, errors.Wrapf(err, "migrated rules doc is invalid")
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,77 @@
+package rule_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+func TestMigrate(t *testing.T) {
+	for _, file := range []string{"testdata/good.json", "testdata/good_2.0.json"} {
+		file := file
+		t.Run(file, func(t *testing.T) {
+			content, _ := ioutil.ReadFile(file)
+
+			original, err := rule.Validate(content)
+			if err != nil {
+				t.Fatalf("Validation of original failed: %+v", err)
+			}
+
+			migratedDoc, err := rule.Migrate(content)
+			if err != nil {
+				t.Fatalf("Migration failed: %+v", err)
+			}
+
+			migrated, err := rule.Validate(migratedDoc)
+			if err != nil {
+				t.Fatalf("Validation of migrated doc failed: %+v", err)
+			}
+
+			if migrated.Schema != rule.LatestSchema {
+				t.Fatalf("Migrated doc has schema %s, expected %s", migrated.Schema, rule.LatestSchema)
+			}
+
+			diffs := deep.Equal(migrated.Policies, original.Policies)
+			if len(diffs) > 0 {
+				t.Fatalf("Migration was lossy: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
+// Migrating only changes the $schema declaration of a document, keeping the order of its keys
+// and its formatting
+func TestMigrateKeepsFormatting(t *testing.T) {
+	content, _ := ioutil.ReadFile("testdata/good.json")
+
+	migrated, err := rule.Migrate(content)
+	if err != nil {
+		t.Fatalf("Migration failed: %+v", err)
+	}
+
+	expected := strings.Replace(string(content), rule.SchemaV1, rule.SchemaV2, 1)
+	if string(migrated) != expected {
+		t.Fatalf("Expected only $schema to change, got\n%s", migrated)
+	}
+}
+
+func TestMigrateInvalid(t *testing.T) {
+	invalidDoc, _ := ioutil.ReadFile("testdata/bad.json")
+
+	_, err := rule.Migrate(invalidDoc)
+	if err == nil {
+		t.Fatalf("Migration of an invalid doc should have failed!")
+	}
+}
+
+func TestMigrateYAML(t *testing.T) {
+	yamlDoc, _ := ioutil.ReadFile("testdata/good_2.0.yaml")
+
+	_, err := rule.Migrate(yamlDoc)
+	if err == nil {
+		t.Fatalf("Migration of a YAML doc should have failed!")
+	}
+}
@@ -1,16 +1,31 @@
 package rule
 
 import (
+	"log"
+	"sort"
+	"time"
+
 	"github.com/pkg/errors"
 )
 
+// DefaultReferenceDate is the date policy rules' effective date ranges are evaluated
+// against, unless the rule or rules document specifies otherwise.
+const DefaultReferenceDate = "${" + NowVariable + "}"
+
 // Policy encapsulates a policy rule.
 type Policy struct {
-	ID           string       `json:"policy-id"`
-	Description  string       `json:"description"`
-	Type         string       `json:"type"`
-	Repositories []Repository `json:"repositories"`
-	Conditions   []Condition  `json:"conditions"`
+	RuleID         string       `json:"rule-id,omitempty"` // identifies the rule.  Generated from its position if absent
+	ID             string       `json:"policy-id"`
+	Description    string       `json:"description"`
+	Message        string       `json:"message,omitempty"` // explanation of why the policy applies, may contain variables
+	Type           string       `json:"type"`
+	Rank           *int         `json:"rank,omitempty"` // explicit position among policies; lower ranks first
+	Repositories   []Repository `json:"repositories"`
+	Conditions     []Condition  `json:"conditions"`
+	EffectiveFrom  string       `json:"effective-from,omitempty"`  // date the rule takes effect (inclusive)
+	EffectiveUntil string       `json:"effective-until,omitempty"` // date the rule ceases to be in effect (exclusive)
+	ReferenceDate  string       `json:"reference-date,omitempty"`  // date or variable evaluated against the effective dates
+	source         string       // path of the rules document containing the rule, if known
 }
 
 // Resolve interpolates any variables in a policy.  if the policy ID resolves to a list,
@@ -27,21 +42,20 @@ func (p Policy) Resolve(variables VariablePinner) (policies []Policy, err error)
 			return nil, errors.Wrapf(err, "could not resolve property ID %s", p.ID)
 		}
 
+		if len(resolvedIDs) == 0 {
+			trace := traceOf(variables)
+			trace.begin(p, p.ID)
+			trace.end(false, nil)
+		}
+
 		for _, id := range resolvedIDs {
 
 			// Now that we have a concrete ID, resolve any other variables elsewhere in the
-			// policy.  Some of them may depend on knowing the ID we just found
-			//
-			// We take a shortcut by pinning only the ID variable, meaning ${foo.bar.baz.id} is pinned,
-			// but ${foo.bar} wouldn't be.  We could walk up the chain of variable segments to pin
-			// the entire path to the id property, but that doesn't seem necessary.
-			resolved, err := Policy{
-				ID:           id,
-				Description:  p.Description,
-				Repositories: p.Repositories,
-				Conditions:   p.Conditions,
-				Type:         p.Type,
-			}.Resolve(variables.Pin(p.ID, id))
+			// policy.  Some of them may depend on knowing the ID we just found, or the objects
+			// that led to it (e.g. the grant whose funder has the policy)
+			concrete := p
+			concrete.ID = id
+			resolved, err := concrete.Resolve(variables.Pin(p.ID, id))
 
 			if err != nil {
 				return nil, errors.Wrapf(err, "could not resolve policy rule for %s", id)
@@ -53,6 +67,10 @@ func (p Policy) Resolve(variables VariablePinner) (policies []Policy, err error)
 
 		// Individual policy.  Resolve the repositories section, and filter by condition to see if
 		// it is applicable
+		var applies bool
+		trace := traceOf(variables)
+		trace.begin(p, p.ID)
+		defer func() { trace.end(applies, err) }()
 
 		p.Repositories, err = p.resolveRepositories(variables)
 		if err != nil {
@@ -60,12 +78,26 @@ func (p Policy) Resolve(variables VariablePinner) (policies []Policy, err error)
 		}
 
 		ok, err := p.applyConditions(variables)
-		if ok && err == nil {
-			resolvedPolicies = append(resolvedPolicies, p)
-		}
 		if err != nil {
 			return nil, errors.Wrapf(err, "error applying conditions to policy %s", p.ID)
 		}
+
+		if ok {
+			ok, err = p.inEffect(variables)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not determine if policy %s is in effect", p.ID)
+			}
+			trace.inEffect(ok)
+		}
+
+		if ok {
+			p.Message, err = Interpolate(p.Message, variables)
+			if err != nil {
+				return nil, errors.Wrapf(err, "could not resolve message of policy %s", p.ID)
+			}
+			resolvedPolicies = append(resolvedPolicies, p)
+			applies = true
+		}
 	}
 
 	return uniquePolicies(resolvedPolicies), err
@@ -73,9 +105,23 @@ func (p Policy) Resolve(variables VariablePinner) (policies []Policy, err error)
 
 // resolveRepositories replaces any variables in the repository section of a policy.  If repository ID
 // is a variable that expands into a list of IDs, then we can have multiple repositories.
+// Repositories with conditions are omitted unless their conditions evaluate to true.
 func (p Policy) resolveRepositories(variables VariableResolver) ([]Repository, error) {
+	trace := traceOf(variables)
+	defer trace.repository("")
+
 	var resolved []Repository
 	for _, repo := range p.Repositories {
+		trace.repository(repo.ID)
+		ok, err := applyConditions(repo.Conditions, variables)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error applying conditions to repository %s in %s", repo.ID, p.ID)
+		}
+		if !ok {
+			continue
+		}
+		repo.Conditions = nil
+
 		repos, err := repo.Resolve(variables)
 		if err != nil {
 			return nil, errors.Wrapf(err, "could not resolve repositories for %s", p.ID)
@@ -88,8 +134,130 @@ func (p Policy) resolveRepositories(variables VariableResolver) ([]Repository, e
 
 // Filter based on evaluating conditions, if there are any
 func (p Policy) applyConditions(variables VariableResolver) (bool, error) {
-	for _, cond := range p.Conditions {
+	return applyConditions(p.Conditions, variables)
+}
+
+// inEffect determines whether the policy's reference date falls within its effective date range.
+// If the reference date has no value (e.g. the submission hasn't been submitted yet), the current
+// time is used instead.  So it is if a variable reference date has a value that isn't a date (e.g.
+// a publication date typed in as "Spring 2020"), with a warning.
+func (p Policy) inEffect(variables VariableResolver) (bool, error) {
+	if p.EffectiveFrom == "" && p.EffectiveUntil == "" {
+		return true, nil
+	}
+
+	reference := p.ReferenceDate
+	if reference == "" {
+		reference = DefaultReferenceDate
+	}
+
+	value, err := singleValued(variables.Resolve(reference))
+	if err != nil {
+		return false, errors.Wrapf(err, "could not resolve reference date %s", reference)
+	}
+
+	date, err := parseDate(value)
+	if value == "" || (err != nil && IsVariable(reference)) {
+		if value != "" {
+			log.Printf("Warning: bad reference date from %s of policy rule %s, using the current time instead: %s",
+				reference, p.RuleID, err)
+		}
+		if date, err = currentTime(variables); err != nil {
+			return false, err
+		}
+	} else if err != nil {
+		return false, errors.Wrapf(err, "bad reference date from %s", reference)
+	}
+
+	if p.EffectiveFrom != "" {
+		from, err := parseDate(p.EffectiveFrom)
+		if err != nil {
+			return false, errors.Wrapf(err, "bad effective-from date")
+		}
+		if date.Before(from) {
+			return false, nil
+		}
+	}
+
+	if p.EffectiveUntil != "" {
+		until, err := parseDate(p.EffectiveUntil)
+		if err != nil {
+			return false, errors.Wrapf(err, "bad effective-until date")
+		}
+		if !date.Before(until) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// currentTime resolves the time of evaluation
+func currentTime(variables VariableResolver) (time.Time, error) {
+	value, err := singleValued(variables.Resolve(DefaultReferenceDate))
+	if err != nil {
+		return time.Time{}, errors.Wrapf(err, "could not resolve reference date %s", DefaultReferenceDate)
+	}
+
+	date, err := parseDate(value)
+	if err != nil {
+		return date, errors.Wrapf(err, "bad reference date from %s", DefaultReferenceDate)
+	}
+
+	return date, nil
+}
+
+// validateEffectiveDates verifies that the policy's effective dates can be parsed, and form a
+// non-empty range, and that its reference date can be parsed if it is literal.
+func (p Policy) validateEffectiveDates() error {
+	var from, until time.Time
+	var err error
+
+	if p.ReferenceDate != "" && !IsVariable(p.ReferenceDate) {
+		if _, err = parseDate(p.ReferenceDate); err != nil {
+			return errors.Wrapf(err, "bad reference-date")
+		}
+	}
+
+	if p.EffectiveFrom != "" {
+		if from, err = parseDate(p.EffectiveFrom); err != nil {
+			return errors.Wrapf(err, "bad effective-from date")
+		}
+	}
+
+	if p.EffectiveUntil != "" {
+		if until, err = parseDate(p.EffectiveUntil); err != nil {
+			return errors.Wrapf(err, "bad effective-until date")
+		}
+	}
+
+	if p.EffectiveFrom != "" && p.EffectiveUntil != "" && !from.Before(until) {
+		return errors.Errorf("effective-from %s is not before effective-until %s", p.EffectiveFrom, p.EffectiveUntil)
+	}
+
+	return nil
+}
+
+// parseDate parses a date (e.g. 2020-01-31, which is interpreted as midnight UTC), or an
+// RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, nil
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return date, errors.Errorf("'%s' is neither a date nor an RFC 3339 timestamp", value)
+	}
+
+	return date, nil
+}
+
+// applyConditions evaluates a list of conditions, returning true only if all are true
+func applyConditions(conditions []Condition, variables VariableResolver) (bool, error) {
+	for _, cond := range conditions {
 		ok, err := cond.Apply(variables)
+		traceOf(variables).condition(cond, ok, err)
 		if !ok || err != nil {
 			return ok, err
 		}
@@ -98,6 +266,14 @@ func (p Policy) applyConditions(variables VariableResolver) (bool, error) {
 	return true, nil
 }
 
+// OrderByRank stably sorts policies by rank.  Ranked policies precede unranked ones, which
+// otherwise remain in the order of the rules that produced them.
+func OrderByRank(policies []Policy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		return rankLess(policies[i].Rank, policies[j].Rank)
+	})
+}
+
 func uniquePolicies(policies []Policy) []Policy {
 
 	if len(policies) < 2 {
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/go-test/deep"
 	"github.com/oa-pass/pass-policy-service/rule"
@@ -83,3 +84,232 @@ func TestPolicy(t *testing.T) {
 	}
 
 }
+
+func TestPolicyRepositoryConditions(t *testing.T) {
+	policy := rule.Policy{
+		ID: "policy",
+		Repositories: []rule.Repository{{
+			ID:       "a",
+			Selected: true,
+		}, {
+			ID: "*",
+			Conditions: []rule.Condition{{
+				"noneOf": []interface{}{
+					map[string]interface{}{
+						"contains": map[string]interface{}{
+							"FACULTY": "${header.Affiliation}",
+						},
+					},
+				},
+			}},
+		}},
+	}
+
+	cases := []struct {
+		testName    string
+		affiliation string
+		expected    []rule.Repository
+	}{{
+		testName:    "faculty",
+		affiliation: "FACULTY",
+		expected: []rule.Repository{
+			{ID: "a", Selected: true},
+		},
+	}, {
+		testName:    "staff",
+		affiliation: "STAFF",
+		expected: []rule.Repository{
+			{ID: "a", Selected: true},
+			{ID: "*"},
+		},
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			policies, err := policy.Resolve(&rule.Context{
+				Headers: map[string][]string{
+					"Affiliation": {c.affiliation},
+				},
+			})
+			if err != nil {
+				t.Fatalf("Failed policy resolve: %+v", err)
+			}
+
+			if len(policies) != 1 {
+				t.Fatalf("Wrong number of policies: %+v", policies)
+			}
+
+			diffs := deep.Equal(policies[0].Repositories, c.expected)
+			if len(diffs) > 0 {
+				t.Fatalf("Found differences in expected repositories: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
+func TestPolicyMessage(t *testing.T) {
+	submissionURI := "http://example.org/submission"
+
+	policy := rule.Policy{
+		ID:      "${submission.grants.primaryFunder.policy}",
+		Message: "Grants ${submission.grants.awardNumber} are funded by ${primaryFunder.name}",
+	}
+
+	variables := &rule.Context{
+		SubmissionURI: submissionURI,
+		PassClient: testFetcher(map[string]string{
+			submissionURI: `{
+				"grants": [
+					"http://example.org/grant/1",
+					"http://example.org/grant/2",
+					"http://example.org/grant/3"
+				]
+			}`,
+			"http://example.org/grant/1": `{
+				"awardNumber": "A1",
+				"primaryFunder": "http://example.org/funder/1"
+			}`,
+			"http://example.org/grant/2": `{
+				"awardNumber": "A2",
+				"primaryFunder": "http://example.org/funder/2"
+			}`,
+			"http://example.org/grant/3": `{
+				"awardNumber": "A3",
+				"primaryFunder": "http://example.org/funder/1"
+			}`,
+			"http://example.org/funder/1": `{
+				"name": "Funder One",
+				"policy": "http://example.org/policy/1"
+			}`,
+			"http://example.org/funder/2": `{
+				"name": "Funder Two",
+				"policy": "http://example.org/policy/2"
+			}`,
+		}),
+	}
+
+	// Values resolved before pinning must not leak into the pinned policies
+	if _, err := variables.Resolve("${submission.grants.awardNumber}"); err != nil {
+		t.Fatalf("Could not resolve award numbers: %+v", err)
+	}
+
+	policies, err := policy.Resolve(variables)
+	if err != nil {
+		t.Fatalf("Failed policy resolve: %+v", err)
+	}
+
+	var messages []string
+	for _, p := range policies {
+		messages = append(messages, p.Message)
+	}
+
+	diffs := deep.Equal(messages, []string{
+		"Grants A1, A3 are funded by Funder One",
+		"Grants A2 are funded by Funder Two",
+	})
+	if len(diffs) > 0 {
+		t.Fatalf("Found differences in expected messages: %s", strings.Join(diffs, "\n"))
+	}
+}
+
+func TestPolicyEffectiveDates(t *testing.T) {
+	submissionURI := "http://example.org/submission"
+	fetcher := testFetcher(map[string]string{
+		submissionURI: `{
+			"submittedDate": "2019-06-01T12:00:00.000Z"
+		}`,
+		"http://example.org/unsubmitted": `{}`,
+		"http://example.org/unparseable": `{
+			"metadata": "{\"publicationDate\": \"Spring 2020\"}"
+		}`,
+	})
+	now, _ := time.Parse(time.RFC3339, "2020-01-01T00:00:00Z")
+
+	cases := []struct {
+		testName   string
+		policy     rule.Policy
+		submission string
+		expected   int
+	}{{
+		testName: "no effective dates",
+		policy:   rule.Policy{ID: "policy"},
+		expected: 1,
+	}, {
+		testName: "in effect now",
+		policy: rule.Policy{
+			ID:             "policy",
+			EffectiveFrom:  "2019-07-01",
+			EffectiveUntil: "2020-07-01",
+		},
+		expected: 1,
+	}, {
+		testName: "not yet in effect",
+		policy: rule.Policy{
+			ID:            "policy",
+			EffectiveFrom: "2020-07-01",
+		},
+		expected: 0,
+	}, {
+		testName: "no longer in effect",
+		policy: rule.Policy{
+			ID:             "policy",
+			EffectiveUntil: "2020-01-01T00:00:00Z",
+		},
+		expected: 0,
+	}, {
+		testName: "in effect at submission",
+		policy: rule.Policy{
+			ID:             "policy",
+			EffectiveUntil: "2019-07-01",
+			ReferenceDate:  "${submission.submittedDate}",
+		},
+		submission: submissionURI,
+		expected:   1,
+	}, {
+		testName: "not in effect at submission",
+		policy: rule.Policy{
+			ID:            "policy",
+			EffectiveFrom: "2019-07-01",
+			ReferenceDate: "${submission.submittedDate}",
+		},
+		submission: submissionURI,
+		expected:   0,
+	}, {
+		testName: "unsubmitted uses current date",
+		policy: rule.Policy{
+			ID:            "policy",
+			EffectiveFrom: "2019-07-01",
+			ReferenceDate: "${submission.submittedDate}",
+		},
+		submission: "http://example.org/unsubmitted",
+		expected:   1,
+	}, {
+		testName: "unparseable date uses current date",
+		policy: rule.Policy{
+			ID:            "policy",
+			EffectiveFrom: "2019-07-01",
+			ReferenceDate: "${submission.metadata.publicationDate}",
+		},
+		submission: "http://example.org/unparseable",
+		expected:   1,
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			policies, err := c.policy.Resolve(&rule.Context{
+				SubmissionURI: c.submission,
+				PassClient:    fetcher,
+				Now:           now,
+			})
+			if err != nil {
+				t.Fatalf("Failed policy resolve: %+v", err)
+			}
+
+			if len(policies) != c.expected {
+				t.Fatalf("Expected %d policies, got %d", c.expected, len(policies))
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package rule
+
+import (
+	"sort"
+)
+
+// Recommendation computes a smallest set of repositories that satisfies the requirements:  all
+// required repositories, and at least one member of each one-of group.  Of the smallest sets,
+// the one with the most repositories selected by default is preferred.  Remaining ties are broken
+// by repository ID, so the recommendation is deterministic.  Optional repositories are never
+// recommended, nor is "*", since it doesn't identify a repository.
+func (r *Requirements) Recommendation() []Repository {
+	chosen := make(map[string]Repository)
+	for _, repo := range r.Required {
+		if repo.ID != "*" {
+			chosen[repo.ID] = repo
+		}
+	}
+
+	// Only groups that aren't satisfied by a required repository need choosing from
+	var unmet [][]Repository
+	for _, group := range r.OneOf {
+		var members []Repository
+		satisfied := false
+		for _, repo := range group {
+			if _, ok := chosen[repo.ID]; ok {
+				satisfied = true
+			}
+			if repo.ID != "*" {
+				members = append(members, repo)
+			}
+		}
+
+		if !satisfied && len(members) > 0 {
+			unmet = append(unmet, members)
+		}
+	}
+
+	s := &coverSearch{groups: unmet}
+	s.search(nil)
+
+	recommendation := make([]Repository, 0, len(chosen)+len(s.best))
+	for _, repo := range chosen {
+		recommendation = append(recommendation, repo)
+	}
+	recommendation = append(recommendation, s.best...)
+
+	sort.Slice(recommendation, func(i, j int) bool {
+		return recommendation[i].ID < recommendation[j].ID
+	})
+
+	return recommendation
+}
+
+// Recommend marks the repositories in the recommendation as recommended, and returns itself
+func (r *Requirements) Recommend() *Requirements {
+	recommended := make(map[string]bool)
+	for _, repo := range r.Recommendation() {
+		recommended[repo.ID] = true
+	}
+
+	for i := range r.Required {
+		r.Required[i].Recommended = recommended[r.Required[i].ID]
+	}
+
+	for i := range r.OneOf {
+		for j := range r.OneOf[i] {
+			r.OneOf[i][j].Recommended = recommended[r.OneOf[i][j].ID]
+		}
+	}
+
+	for i := range r.Optional {
+		r.Optional[i].Recommended = recommended[r.Optional[i].ID]
+	}
+
+	return r
+}
+
+// coverSearch finds the best set of repositories that contains at least one member of each group,
+// by branching on the members of the first group not yet covered.
+type coverSearch struct {
+	groups [][]Repository
+	best   []Repository
+	found  bool
+}
+
+func (s *coverSearch) search(chosen []Repository) {
+	if s.found && len(chosen) > len(s.best) {
+		return
+	}
+
+	var uncovered []Repository
+	for _, group := range s.groups {
+		if !repoListContainsAny(group, chosen) {
+			uncovered = group
+			break
+		}
+	}
+
+	if uncovered == nil {
+		if !s.found || better(chosen, s.best) {
+			s.best = append([]Repository{}, chosen...)
+			s.found = true
+		}
+		return
+	}
+
+	// A member already chosen can't cover the group, so each branch grows the set by one
+	if s.found && len(chosen)+1 > len(s.best) {
+		return
+	}
+
+	for _, member := range uncovered {
+		s.search(append(chosen, member))
+	}
+}
+
+// better determines if one set of repositories is preferable to another:  it is smaller, has
+// more selected repositories, or else comes first by sorted repository IDs
+func better(a, b []Repository) bool {
+	if len(a) != len(b) {
+		return len(a) < len(b)
+	}
+
+	if selectedA, selectedB := countSelected(a), countSelected(b); selectedA != selectedB {
+		return selectedA > selectedB
+	}
+
+	return repoListKey(a) < repoListKey(b)
+}
+
+func countSelected(repos []Repository) int {
+	var n int
+	for _, repo := range repos {
+		if repo.Selected {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,113 @@
+package rule_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+func TestRecommendation(t *testing.T) {
+	cases := []struct {
+		testName     string
+		requirements rule.Requirements
+		expected     []string
+	}{{
+		testName: "nothing required",
+		expected: []string{},
+	}, {
+		testName: "(a or b) and (b or c) -> b",
+		requirements: rule.Requirements{
+			OneOf: [][]rule.Repository{
+				{{ID: "a"}, {ID: "b"}},
+				{{ID: "b"}, {ID: "c"}},
+			},
+		},
+		expected: []string{"b"},
+	}, {
+		testName: "a and (a or b) and (c or d*) -> a, d",
+		requirements: rule.Requirements{
+			Required: []rule.Repository{{ID: "a"}},
+			OneOf: [][]rule.Repository{
+				{{ID: "a"}, {ID: "b"}},
+				{{ID: "c"}, {ID: "d", Selected: true}},
+			},
+			Optional: []rule.Repository{{ID: "e", Selected: true}},
+		},
+		expected: []string{"a", "d"},
+	}, {
+		testName: "(a or b) and (c or d) -> a, c",
+		requirements: rule.Requirements{
+			OneOf: [][]rule.Repository{
+				{{ID: "a"}, {ID: "b"}},
+				{{ID: "c"}, {ID: "d"}},
+			},
+		},
+		expected: []string{"a", "c"},
+	}, {
+		testName: "(a or b*) and (b* or c*) and (a or c*) -> b, c",
+		requirements: rule.Requirements{
+			OneOf: [][]rule.Repository{
+				{{ID: "a"}, {ID: "b", Selected: true}},
+				{{ID: "b", Selected: true}, {ID: "c", Selected: true}},
+				{{ID: "a"}, {ID: "c", Selected: true}},
+			},
+		},
+		expected: []string{"b", "c"},
+	}, {
+		testName: "(a or b*) and (c or d*) and (a or c) -> a, d",
+		requirements: rule.Requirements{
+			OneOf: [][]rule.Repository{
+				{{ID: "a"}, {ID: "b", Selected: true}},
+				{{ID: "c"}, {ID: "d", Selected: true}},
+				{{ID: "a"}, {ID: "c"}},
+			},
+		},
+		expected: []string{"a", "d"},
+	}, {
+		testName: "(a or *) -> a",
+		requirements: rule.Requirements{
+			OneOf: [][]rule.Repository{
+				{{ID: "*"}, {ID: "a"}},
+			},
+		},
+		expected: []string{"a"},
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			ids := []string{}
+			for _, repo := range c.requirements.Recommendation() {
+				ids = append(ids, repo.ID)
+			}
+
+			diffs := deep.Equal(ids, c.expected)
+			if len(diffs) > 0 {
+				t.Fatalf("did not get expected recommendation: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
+func TestRecommend(t *testing.T) {
+	recommended := (&rule.Requirements{
+		Required: []rule.Repository{{ID: "a"}},
+		OneOf: [][]rule.Repository{
+			{{ID: "b"}, {ID: "c"}},
+		},
+		Optional: []rule.Repository{{ID: "d"}},
+	}).Recommend()
+
+	diffs := deep.Equal(recommended, &rule.Requirements{
+		Required: []rule.Repository{{ID: "a", Recommended: true}},
+		OneOf: [][]rule.Repository{
+			{{ID: "b", Recommended: true}, {ID: "c"}},
+		},
+		Optional: []rule.Repository{{ID: "d"}},
+	})
+	if len(diffs) > 0 {
+		t.Fatalf("did not get expected results: %s", strings.Join(diffs, "\n"))
+	}
+}
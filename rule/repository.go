@@ -4,9 +4,30 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Explicit requirement levels of a repository within a policy
+const (
+	RequirementRequired = "required"
+	RequirementOneOf    = "one-of"
+	RequirementOptional = "optional"
+)
+
 type Repository struct {
-	ID       string `json:"repository-id"`
-	Selected bool   `json:"selected"`
+	ID          string       `json:"repository-id"`
+	Selected    bool         `json:"selected"`
+	Ref         string       `json:"$ref,omitempty"`        // reference to a list of repositories in Definitions
+	Conditions  []Condition  `json:"conditions,omitempty"`  // the repository is omitted from its policy unless all are true
+	Requirement string       `json:"requirement,omitempty"` // explicit requirement level; inferred if absent
+	Group       string       `json:"group,omitempty"`       // label of a one-of group, which may span policies
+	Rank        *int         `json:"rank,omitempty"`        // explicit position in requirements; lower ranks first
+	Constraints *Constraints `json:"constraints,omitempty"` // restrictions on deposits that satisfy the policy
+	Sources     []Source     `json:"sources,omitempty"`     // policies that call for the repository, in requirements
+	Recommended bool         `json:"recommended,omitempty"` // part of the recommended set of repositories to deposit into
+}
+
+// Source identifies a policy, and the rule that produced it, that calls for a repository
+type Source struct {
+	PolicyID string `json:"policy-id"`
+	RuleID   string `json:"rule-id,omitempty"`
 }
 
 func (r Repository) Resolve(variables VariableResolver) ([]Repository, error) {
@@ -19,12 +40,9 @@ func (r Repository) Resolve(variables VariableResolver) ([]Repository, error) {
 		}
 
 		for _, id := range resolvedIDs {
-			resolvedRepositories = append(resolvedRepositories,
-				Repository{
-					ID:       id,
-					Selected: r.Selected,
-				},
-			)
+			resolved := r
+			resolved.ID = id
+			resolvedRepositories = append(resolvedRepositories, resolved)
 		}
 	} else {
 		resolvedRepositories = []Repository{r}
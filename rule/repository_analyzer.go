@@ -1,6 +1,7 @@
 package rule
 
 import (
+	"fmt"
 	"sort"
 	"strings"
 )
@@ -11,14 +12,38 @@ type Requirements struct {
 	Required []Repository   `json:"required"`
 	OneOf    [][]Repository `json:"one-of"`
 	Optional []Repository   `json:"optional"`
+
+	clauses map[Source]formula // what each policy calls for, if analyzed from policies
+}
+
+// Actions Keep takes on repositories that are not kept
+const (
+	ElisionRemoved = "removed"
+	ElisionDemoted = "demoted"
+)
+
+// KeepReport describes the repositories that Keep removed from requirements, or demoted to optional
+type KeepReport struct {
+	Elided []Elision `json:"elided"`
+}
+
+// Elision is a repository that Keep removed or demoted, with the requirement level it had, and why
+type Elision struct {
+	ID          string   `json:"repository-id"`
+	Requirement string   `json:"requirement"` // required, one-of, or optional
+	Action      string   `json:"action"`      // removed, or demoted to optional
+	Reason      string   `json:"reason"`
+	Sources     []Source `json:"sources,omitempty"` // policies that called for the repository
 }
 
 // Keep removes any repositories that are not in the keep list)
 // Such repositories are presumed to have been deposited to by some means outside of pass.
-// For example,  for requirements OneOf: {a, b} with keep {a}, the result is Optional {a}
-func (r *Requirements) Keep(keep []Repository) *Requirements {
+// For example,  for requirements OneOf: {a, b} with keep {a}, the result is Optional {a}.
+// The report describes each repository removed or demoted, e.g. b removed and a demoted.
+func (r *Requirements) Keep(keep []Repository) (*Requirements, *KeepReport) {
 
 	requirements := &Requirements{}
+	report := &KeepReport{Elided: []Elision{}}
 
 	shouldKeep := make(map[string]bool, len(keep))
 
@@ -30,34 +55,32 @@ func (r *Requirements) Keep(keep []Repository) *Requirements {
 	for _, repo := range r.Required {
 		if shouldKeep[repo.ID] {
 			requirements.Required = append(requirements.Required, repo)
+		} else {
+			report.elide(repo, RequirementRequired, ElisionRemoved, reasonNotKept)
 		}
 	}
 
 	// remove elided repos from OneOf, and demote the remainders to optional
-	var discard []Repository
-	if len(r.OneOf) > 0 {
-		for _, list := range r.OneOf {
-			for _, repo := range list {
-				if !repoListContains(keep, repo) {
-					discard = append(discard, repo)
-				}
+	for _, list := range r.OneOf {
+		var discard []string
+		for _, repo := range list {
+			if !shouldKeep[repo.ID] {
+				discard = append(discard, repo.ID)
 			}
 		}
 
 		if len(discard) == 0 {
-			requirements.OneOf = r.OneOf
-		} else {
-			for _, list := range r.OneOf {
-				if !repoListContainsAny(list, discard) {
-					requirements.OneOf = append(requirements.OneOf, list)
-					continue
-				}
+			requirements.OneOf = append(requirements.OneOf, append([]Repository(nil), list...))
+			continue
+		}
 
-				for _, member := range list {
-					if repoListContains(keep, member) {
-						requirements.Optional = append(requirements.Optional, member)
-					}
-				}
+		for _, member := range list {
+			if shouldKeep[member.ID] {
+				requirements.Optional = append(requirements.Optional, member)
+				report.elide(member, RequirementOneOf, ElisionDemoted,
+					fmt.Sprintf(reasonGroupNotKept, strings.Join(discard, ", ")))
+			} else {
+				report.elide(member, RequirementOneOf, ElisionRemoved, reasonNotKept)
 			}
 		}
 	}
@@ -66,103 +89,300 @@ func (r *Requirements) Keep(keep []Repository) *Requirements {
 	for _, repo := range r.Optional {
 		if shouldKeep[repo.ID] {
 			requirements.Optional = append(requirements.Optional, repo)
+		} else {
+			report.elide(repo, RequirementOptional, ElisionRemoved, reasonNotKept)
 		}
 	}
 
-	return normalize(requirements)
+	// Like the requirements they are part of, clauses of policies that call for an elided
+	// repository are presumed to be satisfied outside of PASS
+	if r.clauses != nil {
+		requirements.clauses = make(map[Source]formula, len(r.clauses))
+		for source, f := range r.clauses {
+			for _, c := range f {
+				if clauseKept(c, shouldKeep) {
+					requirements.clauses[source] = append(requirements.clauses[source], c)
+				}
+			}
+		}
+	}
+
+	return normalize(requirements), report
+}
+
+// clauseKept determines if every member of a clause, other than "*", is kept
+func clauseKept(c clause, shouldKeep map[string]bool) bool {
+	for _, member := range c {
+		if member.ID != "*" && !shouldKeep[member.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+const (
+	reasonNotKept      = "not called for by the submission's effective policies"
+	reasonGroupNotKept = "its one-of group also contains %s, not called for by the submission's effective policies, " +
+		"so the group is presumed to be satisfied outside of PASS"
+)
+
+// elide reports a repository as removed or demoted.  A repository in several one-of groups is
+// reported once.
+func (k *KeepReport) elide(repo Repository, requirement, action, reason string) {
+	for _, elision := range k.Elided {
+		if elision.ID == repo.ID && elision.Requirement == requirement && elision.Action == action {
+			return
+		}
+	}
+
+	k.Elided = append(k.Elided, Elision{
+		ID:          repo.ID,
+		Requirement: requirement,
+		Action:      action,
+		Reason:      reason,
+		Sources:     repo.Sources,
+	})
+}
+
+// TranslateURIs applies the given function to all URIs (mutating them), and returns itself
+func (k *KeepReport) TranslateURIs(replace func(string) (string, bool)) *KeepReport {
+	for i := range k.Elided {
+		k.Elided[i].ID, _ = replace(k.Elided[i].ID)
+		k.Elided[i].Sources = translateSources(k.Elided[i].Sources, replace)
+	}
+
+	return k
 }
 
 // TranslateURIs applies the given function to all URIs (mutating them), and returns itself
 func (r *Requirements) TranslateURIs(replace func(string) (string, bool)) *Requirements {
+	translate := func(repo *Repository) {
+		repo.ID, _ = replace(repo.ID)
+		repo.Sources = translateSources(repo.Sources, replace)
+	}
+
 	for i := range r.Required {
-		r.Required[i].ID, _ = replace(r.Required[i].ID)
+		translate(&r.Required[i])
 	}
 
 	for i := range r.OneOf {
 		for j := range r.OneOf[i] {
-			r.OneOf[i][j].ID, _ = replace(r.OneOf[i][j].ID)
+			translate(&r.OneOf[i][j])
 		}
 	}
 
 	for i := range r.Optional {
-		r.Optional[i].ID, _ = replace(r.Optional[i].ID)
+		translate(&r.Optional[i])
+	}
+
+	if r.clauses != nil {
+		clauses := make(map[Source]formula, len(r.clauses))
+		for source, f := range r.clauses {
+			source.PolicyID, _ = replace(source.PolicyID)
+			for _, c := range f {
+				translated := make(clause, 0, len(c))
+				for _, member := range c {
+					member.ID, _ = replace(member.ID)
+					translated = append(translated, member)
+				}
+				clauses[source] = append(clauses[source], translated)
+			}
+		}
+		r.clauses = clauses
 	}
 
 	return r
 }
 
+// translateSources applies the given function to the policy URIs of a list of sources.  Sources
+// may be shared with other requirements, so a translated copy is returned.
+func translateSources(sources []Source, replace func(string) (string, bool)) []Source {
+	if len(sources) == 0 {
+		return sources
+	}
+
+	translated := make([]Source, 0, len(sources))
+	for _, source := range sources {
+		source.PolicyID, _ = replace(source.PolicyID)
+		translated = append(translated, source)
+	}
+
+	return translated
+}
+
 // AnalyzeRequirements analyzes a list of policies, and returns
-// repository requirements
+// repository requirements.  Repositories with an explicit requirement level are
+// placed accordingly, otherwise the level is inferred from the policies.  Where
+// several policies constrain deposits into the same repository, the strictest
+// constraints win.  Repositories are selected if any policy selects them.
 func AnalyzeRequirements(policies []Policy) *Requirements {
+	return analyzeWithSelection(policies, nil)
+}
 
-	// First, sort into "required" and "one of", straight from the policy list
-	requirements := categorize(policies)
-	var optional []Repository
-
-	// If there are required repos, go through the oneOf lists.
-	// If the oneOf list has a required repo, then place remaining into optional
-	requirements.OneOf, optional = cutFrom(requirements.OneOf, requirements.Required)
-	requirements.Optional = append(requirements.Optional, optional...)
-
-	// If we have any required repos, or more than one OneOf group, go through the remaining lists.
-	// if there are any stars, discard and place remaining into optional
-	if len(requirements.Required) > 0 || len(requirements.OneOf) > 0 {
-		requirements.OneOf, optional = cutFrom(requirements.OneOf, []Repository{{ID: "*"}})
-		requirements.Optional = append(requirements.Optional, optional...)
-	}
-
-	// If there are any optional in oneOf, remove it from optional
-	if len(requirements.OneOf) > 0 {
-		optional = optional[:0]
-		for _, list := range requirements.OneOf {
-			for _, optRepo := range requirements.Optional {
-				if !repoListContains(list, optRepo) {
-					optional = append(optional, optRepo)
-				}
+func analyzeWithSelection(policies []Policy, selection *Selection) *Requirements {
+	return analyzeRequirements(policies).
+		withConstraints(mergeConstraints(policies)).
+		withSelection(selection, policies).
+		withOrder(policies)
+}
+
+func analyzeRequirements(policies []Policy) *Requirements {
+	f, explicitOptional := toFormula(policies)
+
+	// Clauses containing "*" are satisfied by depositing anywhere, so the remaining clauses
+	// are the ones that constrain which repositories are deposited into
+	var constraining formula
+	var suggested []Repository
+	for _, c := range f {
+		if !c.isSoft() {
+			constraining = append(constraining, c)
+			continue
+		}
+		for _, repo := range c {
+			if repo.ID != "*" {
+				suggested = append(suggested, repo)
 			}
 		}
-		requirements.Optional = optional
 	}
 
-	// If there are any optional in required, remove it from optional
-	if len(requirements.Required) > 0 {
-		optional = optional[:0]
-		for _, optRepo := range requirements.Optional {
-			if !repoListContains(requirements.Required, optRepo) {
-				optional = append(optional, optRepo)
+	clauses := f.bySource()
+	minimal, dropped := constraining.simplify()
+
+	requirements := &Requirements{clauses: clauses}
+	for _, c := range minimal {
+		if len(c) == 1 {
+			requirements.Required = append(requirements.Required, c[0])
+		} else {
+			requirements.OneOf = append(requirements.OneOf, c)
+		}
+	}
+
+	// A submission must be deposited somewhere.  If nothing else is called for, that means
+	// one of the suggested repositories.
+	if len(minimal) == 0 && len(suggested) > 0 {
+		suggested = uniqueRepos(suggested)
+		if len(suggested) == 1 {
+			requirements.Required = suggested
+		} else {
+			requirements.OneOf = [][]Repository{suggested}
+		}
+		suggested = nil
+	}
+
+	// Any other repositories are optional, unless otherwise required.  Explicitly
+	// optional repositories are never promoted.
+	for _, repo := range append(append(dropped, suggested...), explicitOptional...) {
+		if !repoListContains(requirements.Required, repo) && !repoListsContain(requirements.OneOf, repo) {
+			requirements.Optional = append(requirements.Optional, repo)
+		}
+	}
+
+	return normalize(requirements)
+}
+
+// mergeConstraints merges the constraints on each repository from all policies, by repository ID
+func mergeConstraints(policies []Policy) map[string]*Constraints {
+	constraints := make(map[string]*Constraints)
+	for _, p := range policies {
+		for _, repo := range p.Repositories {
+			if repo.Constraints != nil {
+				constraints[repo.ID] = constraints[repo.ID].Merge(repo.Constraints)
 			}
 		}
+	}
+
+	return constraints
+}
 
-		requirements.Optional = optional
+// ConstrainBy sets the constraints of each repository to those the given policies place on it
+// (mutating them), and returns itself.  The IDs of the policies' repositories are translated by the
+// given function, so they match those of translated requirements.  For example, requirements kept
+// for a submission's effective policies are constrained by just those policies.
+func (r *Requirements) ConstrainBy(policies []Policy, replace func(string) (string, bool)) *Requirements {
+	constraints := make(map[string]*Constraints)
+	for id, c := range mergeConstraints(policies) {
+		id, _ = replace(id)
+		constraints[id] = constraints[id].Merge(c)
 	}
 
-	// if there are no OneOf or required, promote optional to OneOf
-	if len(requirements.OneOf)+len(requirements.Required) == 0 {
-		requirements.OneOf = append(requirements.OneOf, requirements.Optional)
-		requirements.Optional = nil
+	return r.withConstraints(constraints)
+}
+
+// withConstraints sets the constraints of each repository to the given constraints for its ID
+func (r *Requirements) withConstraints(constraints map[string]*Constraints) *Requirements {
+	for i := range r.Required {
+		r.Required[i].Constraints = constraints[r.Required[i].ID]
 	}
 
-	// If there is no required, and only one single-membered oneOf, promote it to required.
-	if len(requirements.Required) == 0 && len(requirements.OneOf) == 1 && len(requirements.OneOf[0]) == 1 {
-		requirements.Required = append(requirements.Required, requirements.OneOf[0][0])
-		requirements.OneOf = nil
+	for i := range r.OneOf {
+		for j := range r.OneOf[i] {
+			r.OneOf[i][j].Constraints = constraints[r.OneOf[i][j].ID]
+		}
 	}
 
-	return normalize(requirements)
+	for i := range r.Optional {
+		r.Optional[i].Constraints = constraints[r.Optional[i].ID]
+	}
+
+	return r
 }
 
-// Sort repos from a set of policies into "required" and "one of buckets"
-func categorize(policies []Policy) *Requirements {
-	requirements := Requirements{}
+// withOrder orders repositories by rank, then by the order in which the policies first list
+// them.  Ranked repositories precede unranked ones, and where policies rank a repository
+// differently, the lowest rank wins.  Members of one-of groups are ordered likewise, and groups
+// are ordered by their members.
+func (r *Requirements) withOrder(policies []Policy) *Requirements {
+	position := make(map[string]int)
+	rank := make(map[string]*int)
 	for _, p := range policies {
-		if len(p.Repositories) == 1 && p.Repositories[0].ID != "*" {
-			requirements.Required = append(requirements.Required, p.Repositories[0])
-		} else if len(p.Repositories) > 1 {
-			requirements.OneOf = append(requirements.OneOf, p.Repositories)
+		for _, repo := range p.Repositories {
+			if _, ok := position[repo.ID]; !ok {
+				position[repo.ID] = len(position)
+			}
+			if rankLess(repo.Rank, rank[repo.ID]) {
+				rank[repo.ID] = repo.Rank
+			}
 		}
 	}
 
-	return normalize(&requirements)
+	less := func(a, b Repository) bool {
+		if rankLess(rank[a.ID], rank[b.ID]) || rankLess(rank[b.ID], rank[a.ID]) {
+			return rankLess(rank[a.ID], rank[b.ID])
+		}
+		return position[a.ID] < position[b.ID]
+	}
+
+	order := func(repos []Repository) {
+		sort.SliceStable(repos, func(i, j int) bool {
+			return less(repos[i], repos[j])
+		})
+	}
+
+	order(r.Required)
+	order(r.Optional)
+	for _, group := range r.OneOf {
+		order(group)
+	}
+
+	sort.SliceStable(r.OneOf, func(i, j int) bool {
+		a, b := r.OneOf[i], r.OneOf[j]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k].ID != b[k].ID {
+				return less(a[k], b[k])
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	return r
+}
+
+// rankLess determines if one rank precedes another.  Any rank precedes no rank.
+func rankLess(a, b *int) bool {
+	if a == nil {
+		return false
+	}
+	return b == nil || *a < *b
 }
 
 func normalize(in *Requirements) *Requirements {
@@ -173,22 +393,20 @@ func normalize(in *Requirements) *Requirements {
 	return in
 }
 
-// Sort and make a list of repos unique.  Where two members pointing to the same
-// repo differ in their selected value, "true" wins.
+// Make a list of repos unique, preserving the order in which they first appear.  Where several
+// members point to the same repo, the first is kept, and their sources are combined.  Which
+// repos are selected is decided separately, according to a selection strategy.
 func uniqueRepos(repos []Repository) []Repository {
 	uniqueRepos := make([]Repository, 0, len(repos))
-	sort.Slice(repos, func(i, j int) bool {
-		return repos[i].ID < repos[j].ID
-	})
+	index := make(map[string]int, len(repos))
 
-	last := &Repository{}
 	for _, repo := range repos {
-		if repo.ID == last.ID {
-			last.Selected = last.Selected || repo.Selected
-		} else {
-			uniqueRepos = append(uniqueRepos, repo)
-			last = &uniqueRepos[len(uniqueRepos)-1]
+		if i, ok := index[repo.ID]; ok {
+			uniqueRepos[i].Sources = mergeSources(uniqueRepos[i].Sources, repo.Sources)
+			continue
 		}
+		index[repo.ID] = len(uniqueRepos)
+		uniqueRepos = append(uniqueRepos, repo)
 	}
 
 	return uniqueRepos
@@ -204,59 +422,69 @@ func repoListKey(repos []Repository) string {
 	return strings.Join(uris, ";")
 }
 
-type keyedRepoList struct {
-	key  string
-	list []Repository
-}
-
+// Make a list of lists of repos unique, preserving the order in which they first appear.
+// Lists are identical if they have the same members, in any order.  The sources of the
+// members of identical lists are combined.
 func uniqueRepoLists(lists [][]Repository) [][]Repository {
-
 	uniqueLists := make([][]Repository, 0, len(lists))
-	keyedLists := make([]*keyedRepoList, len(lists))
-	for i, v := range lists {
-		keyedLists[i] = &keyedRepoList{
-			key:  repoListKey(v),
-			list: uniqueRepos(v),
-		}
-	}
+	index := make(map[string]int, len(lists))
 
-	sort.Slice(keyedLists, func(i, j int) bool {
-		return keyedLists[i].key < keyedLists[j].key
-	})
+	for _, list := range lists {
+		if len(list) == 0 {
+			continue
+		}
 
-	last := &keyedRepoList{}
-	for _, list := range keyedLists {
-		if list.key != last.key {
-			uniqueLists = append(uniqueLists, list.list)
-			last = list
+		list = uniqueRepos(list)
+		key := repoListKey(list)
+		if i, ok := index[key]; ok {
+			mergeMemberSources(uniqueLists[i], list)
+			continue
 		}
+		index[key] = len(uniqueLists)
+		uniqueLists = append(uniqueLists, list)
 	}
 
 	return uniqueLists
 }
 
-// Iterate through a list of lists of repositories.  If any of the lists contains
-// a member of the cutlist, remove that list from the list, and return the remaining members.
-// For example{{a,b},{c,d}} with a cutlist of {b} would return {{c,d}} and {a}
-func cutFrom(lists [][]Repository, cutlist []Repository) (resultingList [][]Repository, remaining []Repository) {
-	if len(cutlist) == 0 {
-		return lists, remaining
+// mergeMemberSources combines the sources of each member of a list of repos with the
+// sources of the same repo in another list
+func mergeMemberSources(list, other []Repository) {
+	for i := range list {
+		for _, repo := range other {
+			if repo.ID == list[i].ID {
+				list[i].Sources = mergeSources(list[i].Sources, repo.Sources)
+			}
+		}
 	}
+}
 
-	for _, list := range lists {
-		for _, cut := range cutlist {
-			if repoListContains(list, cut) {
-				for _, member := range list {
-					if member.ID != cut.ID {
-						remaining = append(remaining, member)
-					}
-				}
-			} else {
-				resultingList = append(resultingList, list)
-			}
+// mergeSources combines two lists of sources into a new, sorted list without duplicates
+func mergeSources(a, b []Source) []Source {
+	if len(b) == 0 {
+		return a
+	}
+	if len(a) == 0 {
+		return b
+	}
+
+	var merged []Source
+	encountered := make(map[Source]bool, len(a)+len(b))
+	for _, source := range append(append([]Source{}, a...), b...) {
+		if !encountered[source] {
+			merged = append(merged, source)
+			encountered[source] = true
 		}
 	}
-	return
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].PolicyID != merged[j].PolicyID {
+			return merged[i].PolicyID < merged[j].PolicyID
+		}
+		return merged[i].RuleID < merged[j].RuleID
+	})
+
+	return merged
 }
 
 func repoListContains(list []Repository, repo Repository) bool {
@@ -268,6 +496,15 @@ func repoListContains(list []Repository, repo Repository) bool {
 	return false
 }
 
+func repoListsContain(lists [][]Repository, repo Repository) bool {
+	for _, list := range lists {
+		if repoListContains(list, repo) {
+			return true
+		}
+	}
+	return false
+}
+
 func repoListContainsAny(list, stuff []Repository) bool {
 	for _, member := range list {
 		for _, thing := range stuff {
@@ -90,7 +90,7 @@ func TestAnalyzeRequirements(t *testing.T) {
 			Required: emptyRepos,
 			OneOf: [][]rule.Repository{{
 				{ID: "a", Selected: true},
-				{ID: "b", Selected: false},
+				{ID: "b", Selected: true},
 			}, {
 				{ID: "b", Selected: true},
 				{ID: "c", Selected: false},
@@ -190,6 +190,127 @@ func TestAnalyzeRequirements(t *testing.T) {
 			OneOf:    emptyRepoList,
 			Optional: emptyRepos,
 		},
+	}, {
+		testName: "explicitly optional a and (b or c) -> (b or c) optional a",
+		policies: []rule.Policy{{
+			Repositories: []rule.Repository{
+				{ID: "a", Selected: true, Requirement: rule.RequirementOptional},
+			},
+		}, {
+			Repositories: []rule.Repository{
+				{ID: "b", Selected: true},
+				{ID: "c", Selected: false},
+			},
+		}},
+		expected: &rule.Requirements{
+			Required: emptyRepos,
+			OneOf: [][]rule.Repository{{
+				{ID: "b", Selected: true},
+				{ID: "c", Selected: false},
+			}},
+			Optional: []rule.Repository{
+				{ID: "a", Selected: true},
+			},
+		},
+	}, {
+		testName: "explicitly optional a and b -> optional a, b",
+		policies: []rule.Policy{{
+			Repositories: []rule.Repository{
+				{ID: "a", Selected: true, Requirement: rule.RequirementOptional},
+				{ID: "b", Selected: false, Requirement: rule.RequirementOptional},
+			},
+		}},
+		expected: &rule.Requirements{
+			Required: emptyRepos,
+			OneOf:    emptyRepoList,
+			Optional: []rule.Repository{
+				{ID: "a", Selected: true},
+				{ID: "b", Selected: false},
+			},
+		},
+	}, {
+		testName: "explicitly required a with b or c -> a and (b or c)",
+		policies: []rule.Policy{{
+			Repositories: []rule.Repository{
+				{ID: "a", Selected: true, Requirement: rule.RequirementRequired},
+				{ID: "b", Selected: true},
+				{ID: "c", Selected: false},
+			},
+		}},
+		expected: &rule.Requirements{
+			Required: []rule.Repository{
+				{ID: "a", Selected: true},
+			},
+			OneOf: [][]rule.Repository{{
+				{ID: "b", Selected: true},
+				{ID: "c", Selected: false},
+			}},
+			Optional: emptyRepos,
+		},
+	}, {
+		testName: "a and b in labeled one-of group -> (a or b)",
+		policies: []rule.Policy{{
+			Repositories: []rule.Repository{
+				{ID: "a", Selected: true, Requirement: rule.RequirementOneOf, Group: "oa"},
+			},
+		}, {
+			Repositories: []rule.Repository{
+				{ID: "b", Selected: false, Requirement: rule.RequirementOneOf, Group: "oa"},
+			},
+		}},
+		expected: &rule.Requirements{
+			Required: emptyRepos,
+			OneOf: [][]rule.Repository{{
+				{ID: "a", Selected: true},
+				{ID: "b", Selected: false},
+			}},
+			Optional: emptyRepos,
+		},
+	}, {
+		testName: "explicitly one-of a, b and inferred c -> c and (a or b)",
+		policies: []rule.Policy{{
+			Repositories: []rule.Repository{
+				{ID: "a", Selected: true, Requirement: rule.RequirementOneOf},
+				{ID: "b", Selected: false, Requirement: rule.RequirementOneOf},
+				{ID: "c", Selected: false},
+			},
+		}},
+		expected: &rule.Requirements{
+			Required: []rule.Repository{
+				{ID: "c", Selected: false},
+			},
+			OneOf: [][]rule.Repository{{
+				{ID: "a", Selected: true},
+				{ID: "b", Selected: false},
+			}},
+			Optional: emptyRepos,
+		},
+	}, {
+		testName: "a and b and (c or d) -> a and b and (c or d)",
+		policies: []rule.Policy{{
+			Repositories: []rule.Repository{{ID: "a"}},
+		}, {
+			Repositories: []rule.Repository{{ID: "b"}},
+		}, {
+			Repositories: []rule.Repository{{ID: "c"}, {ID: "d"}},
+		}},
+		expected: &rule.Requirements{
+			Required: []rule.Repository{{ID: "a"}, {ID: "b"}},
+			OneOf:    [][]rule.Repository{{{ID: "c"}, {ID: "d"}}},
+			Optional: emptyRepos,
+		},
+	}, {
+		testName: "(a or b) and (a or b or c) -> (a or b) optional c",
+		policies: []rule.Policy{{
+			Repositories: []rule.Repository{{ID: "a"}, {ID: "b"}, {ID: "c"}},
+		}, {
+			Repositories: []rule.Repository{{ID: "a"}, {ID: "b"}},
+		}},
+		expected: &rule.Requirements{
+			Required: emptyRepos,
+			OneOf:    [][]rule.Repository{{{ID: "a"}, {ID: "b"}}},
+			Optional: []rule.Repository{{ID: "c"}},
+		},
 	}}
 
 	for _, c := range cases {
@@ -204,12 +325,175 @@ func TestAnalyzeRequirements(t *testing.T) {
 	}
 }
 
+func TestAnalyzeConstraints(t *testing.T) {
+	months := func(n int) *int { return &n }
+
+	policies := []rule.Policy{{
+		Repositories: []rule.Repository{{
+			ID: "a",
+			Constraints: &rule.Constraints{
+				MaxEmbargoMonths:   months(12),
+				ManuscriptVersions: []string{"accepted", "published"},
+			},
+		}},
+	}, {
+		Repositories: []rule.Repository{{
+			ID: "a",
+			Constraints: &rule.Constraints{
+				MaxEmbargoMonths:    months(6),
+				DepositDeadlineDays: months(90),
+				ManuscriptVersions:  []string{"accepted"},
+			},
+		}, {
+			ID: "b",
+			Constraints: &rule.Constraints{
+				ManuscriptVersions: []string{"published"},
+			},
+		}},
+	}, {
+		Repositories: []rule.Repository{{
+			ID: "b",
+			Constraints: &rule.Constraints{
+				MaxEmbargoMonths:   months(24),
+				ManuscriptVersions: []string{"accepted"},
+			},
+		}, {
+			ID: "*",
+		}},
+	}}
+
+	// Constraints from every policy apply, whichever bucket the repository ends up in
+	expected := &rule.Requirements{
+		Required: []rule.Repository{{
+			ID: "a",
+			Constraints: &rule.Constraints{
+				MaxEmbargoMonths:    months(6),
+				DepositDeadlineDays: months(90),
+				ManuscriptVersions:  []string{"accepted"},
+			},
+		}},
+		OneOf: emptyRepoList,
+		Optional: []rule.Repository{{
+			ID: "b",
+			Constraints: &rule.Constraints{
+				MaxEmbargoMonths:   months(24),
+				ManuscriptVersions: []string{},
+			},
+		}},
+	}
+
+	analyzed := rule.AnalyzeRequirements(policies)
+	diffs := deep.Equal(analyzed, expected)
+	if len(diffs) > 0 {
+		t.Fatalf("did not get expected results: %s\n%+v", strings.Join(diffs, "\n"), analyzed)
+	}
+}
+
+// Each repository is annotated with the policies (and rules) that call for it
+func TestAnalyzeProvenance(t *testing.T) {
+	policies := []rule.Policy{{
+		ID:           "p1",
+		RuleID:       "r1",
+		Repositories: []rule.Repository{{ID: "c"}},
+	}, {
+		ID:           "p5",
+		RuleID:       "r5",
+		Repositories: []rule.Repository{{ID: "c"}, {ID: "e"}},
+	}, {
+		ID:           "p2",
+		RuleID:       "r2",
+		Repositories: []rule.Repository{{ID: "a"}, {ID: "b"}},
+	}, {
+		ID:           "p3",
+		RuleID:       "r3",
+		Repositories: []rule.Repository{{ID: "b"}, {ID: "a"}},
+	}, {
+		ID:           "p4",
+		RuleID:       "r4",
+		Repositories: []rule.Repository{{ID: "d"}, {ID: "*"}},
+	}}
+
+	expected := &rule.Requirements{
+		Required: []rule.Repository{
+			// c satisfies p5 as well, which would otherwise require c or e
+			{ID: "c", Sources: []rule.Source{{PolicyID: "p1", RuleID: "r1"}, {PolicyID: "p5", RuleID: "r5"}}},
+		},
+		OneOf: [][]rule.Repository{{
+			{ID: "a", Sources: []rule.Source{{PolicyID: "p2", RuleID: "r2"}, {PolicyID: "p3", RuleID: "r3"}}},
+			{ID: "b", Sources: []rule.Source{{PolicyID: "p2", RuleID: "r2"}, {PolicyID: "p3", RuleID: "r3"}}},
+		}},
+		Optional: []rule.Repository{
+			{ID: "e", Sources: []rule.Source{{PolicyID: "p5", RuleID: "r5"}}},
+			{ID: "d", Sources: []rule.Source{{PolicyID: "p4", RuleID: "r4"}}},
+		},
+	}
+
+	analyzed := rule.AnalyzeRequirements(policies)
+	diffs := deep.Equal(analyzed, expected)
+	if len(diffs) > 0 {
+		t.Fatalf("did not get expected results: %s\n%+v", strings.Join(diffs, "\n"), analyzed)
+	}
+
+	// Provenance survives eliding repositories
+	kept, _ := analyzed.Keep([]rule.Repository{{ID: "a"}, {ID: "c"}, {ID: "d"}})
+	diffs = deep.Equal(kept, &rule.Requirements{
+		Required: expected.Required,
+		OneOf:    emptyRepoList,
+		Optional: []rule.Repository{expected.OneOf[0][0], expected.Optional[1]},
+	})
+	if len(diffs) > 0 {
+		t.Fatalf("did not get expected results: %s\n%+v", strings.Join(diffs, "\n"), kept)
+	}
+}
+
+func TestAnalyzeOrder(t *testing.T) {
+	rank := func(r int) *int { return &r }
+
+	policies := []rule.Policy{{
+		Repositories: []rule.Repository{{ID: "z"}, {ID: "y"}},
+	}, {
+		Repositories: []rule.Repository{{ID: "x"}},
+	}, {
+		Repositories: []rule.Repository{{ID: "w", Rank: rank(1)}},
+	}, {
+		Repositories: []rule.Repository{
+			{ID: "v", Requirement: rule.RequirementOptional},
+			{ID: "u", Requirement: rule.RequirementOptional, Rank: rank(2)},
+		},
+	}, {
+		Repositories: []rule.Repository{{ID: "t"}, {ID: "s", Rank: rank(3)}},
+	}, {
+		Repositories: []rule.Repository{{ID: "s", Rank: rank(0)}, {ID: "t"}},
+	}}
+
+	// Ranked repositories come first, lowest rank winning, then the rest in the order they're listed
+	expected := &rule.Requirements{
+		Required: []rule.Repository{{ID: "w"}, {ID: "x"}},
+		OneOf: [][]rule.Repository{
+			{{ID: "s"}, {ID: "t"}},
+			{{ID: "z"}, {ID: "y"}},
+		},
+		Optional: []rule.Repository{{ID: "u"}, {ID: "v"}},
+	}
+
+	analyzed := rule.AnalyzeRequirements(policies)
+	diffs := deep.Equal(analyzed, expected)
+	if len(diffs) > 0 {
+		t.Fatalf("did not get expected results: %s\n%+v", strings.Join(diffs, "\n"), analyzed)
+	}
+}
+
 func TestKeep(t *testing.T) {
+	notKept := "not called for by the submission's effective policies"
+	groupNotKept := "its one-of group also contains d, not called for by the submission's effective policies, " +
+		"so the group is presumed to be satisfied outside of PASS"
+
 	cases := []struct {
 		testName     string
 		keep         []rule.Repository
 		requirements *rule.Requirements
 		expected     *rule.Requirements
+		elided       []rule.Elision
 	}{{
 		testName: "keep b from a and (c or d) optional b -> optional b",
 		keep: []rule.Repository{
@@ -234,6 +518,11 @@ func TestKeep(t *testing.T) {
 				{ID: "b"},
 			},
 		},
+		elided: []rule.Elision{
+			{ID: "a", Requirement: rule.RequirementRequired, Action: rule.ElisionRemoved, Reason: notKept},
+			{ID: "c", Requirement: rule.RequirementOneOf, Action: rule.ElisionRemoved, Reason: notKept},
+			{ID: "d", Requirement: rule.RequirementOneOf, Action: rule.ElisionRemoved, Reason: notKept},
+		},
 	}, {
 		testName: "keep {a, b} from c and (a or d) and (b or d) -> optional a, b",
 		keep: []rule.Repository{
@@ -261,6 +550,12 @@ func TestKeep(t *testing.T) {
 				{ID: "b"},
 			},
 		},
+		elided: []rule.Elision{
+			{ID: "c", Requirement: rule.RequirementRequired, Action: rule.ElisionRemoved, Reason: notKept},
+			{ID: "a", Requirement: rule.RequirementOneOf, Action: rule.ElisionDemoted, Reason: groupNotKept},
+			{ID: "d", Requirement: rule.RequirementOneOf, Action: rule.ElisionRemoved, Reason: notKept},
+			{ID: "b", Requirement: rule.RequirementOneOf, Action: rule.ElisionDemoted, Reason: groupNotKept},
+		},
 	}, {
 		testName: "keep {a, b, c} from a and (b or c) optional d -> a and (b or c)",
 		keep: []rule.Repository{
@@ -290,15 +585,23 @@ func TestKeep(t *testing.T) {
 			}},
 			Optional: emptyRepos,
 		},
+		elided: []rule.Elision{
+			{ID: "d", Requirement: rule.RequirementOptional, Action: rule.ElisionRemoved, Reason: notKept},
+		},
 	}}
 
 	for _, c := range cases {
 		c := c
 		t.Run(c.testName, func(t *testing.T) {
-			elided := c.requirements.Keep(c.keep)
-			diffs := deep.Equal(elided, c.expected)
+			kept, report := c.requirements.Keep(c.keep)
+			diffs := deep.Equal(kept, c.expected)
+			if len(diffs) > 0 {
+				t.Fatalf("did not get expected results: %s\n%+v", strings.Join(diffs, "\n"), kept)
+			}
+
+			diffs = deep.Equal(report.Elided, c.elided)
 			if len(diffs) > 0 {
-				t.Fatalf("did not get expected results: %s\n%+v", strings.Join(diffs, "\n"), elided)
+				t.Fatalf("did not get expected report: %s\n%+v", strings.Join(diffs, "\n"), report.Elided)
 			}
 		})
 	}
@@ -314,7 +617,7 @@ func TestTranslate(t *testing.T) {
 			{ID: "c"},
 			{ID: "d"},
 		}, {
-			{ID: "e"},
+			{ID: "e", Sources: []rule.Source{{PolicyID: "p", RuleID: "r"}}},
 			{ID: "f"},
 		}},
 		Optional: []rule.Repository{
@@ -333,7 +636,7 @@ func TestTranslate(t *testing.T) {
 			{ID: "C"},
 			{ID: "D"},
 		}, {
-			{ID: "E"},
+			{ID: "E", Sources: []rule.Source{{PolicyID: "P", RuleID: "r"}}},
 			{ID: "F"},
 		}},
 		Optional: []rule.Repository{
@@ -0,0 +1,173 @@
+package rule
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Selection strategies, which decide whether a repository is selected by default when the
+// policies listing it disagree
+const (
+	SelectAny          = "any-selected"  // selected if any policy selects it
+	SelectRuleOrder    = "rule-order"    // the first rule listing the repository decides
+	SelectTypePriority = "type-priority" // the policy with the highest priority type decides, then the first rule
+)
+
+// DefaultTypePriority is the priority of policy types for the type-priority strategy, highest
+// first, unless the rules document specifies otherwise:  institutions over funders
+var DefaultTypePriority = []string{"institution", "funder"}
+
+// Selection describes how to decide which repositories are selected by default
+type Selection struct {
+	Strategy     string   `json:"strategy,omitempty"`      // one of the selection strategies.  Defaults to any-selected
+	TypePriority []string `json:"type-priority,omitempty"` // policy types, highest priority first
+	OnePerGroup  bool     `json:"one-per-group,omitempty"` // select at most one member of each one-of group
+}
+
+// decision is whether a repository is selected, and the rank of the policy that decided so.
+// Lower ranks have higher priority.
+type decision struct {
+	selected bool
+	rank     int
+}
+
+func (s *Selection) strategy() string {
+	if s == nil || s.Strategy == "" {
+		return SelectAny
+	}
+	return s.Strategy
+}
+
+func (s *Selection) typePriority() []string {
+	if s == nil || len(s.TypePriority) == 0 {
+		return DefaultTypePriority
+	}
+	return s.TypePriority
+}
+
+// rank ranks a policy by its position in the policy list, and for the type-priority strategy,
+// first by the priority of its type.  Types without a priority rank last.
+func (s *Selection) rank(p Policy, position, count int) int {
+	if s.strategy() != SelectTypePriority {
+		return position
+	}
+
+	priority := s.typePriority()
+	typeRank := len(priority)
+	for i, t := range priority {
+		if t == p.Type {
+			typeRank = i
+			break
+		}
+	}
+
+	return typeRank*count + position
+}
+
+// decide decides whether each repository listed by the policies is selected, by repository ID
+func (s *Selection) decide(policies []Policy) map[string]decision {
+	decisions := make(map[string]decision)
+	for i, p := range policies {
+		rank := s.rank(p, i, len(policies))
+		for _, repo := range p.Repositories {
+			current, ok := decisions[repo.ID]
+			switch {
+			case !ok:
+				decisions[repo.ID] = decision{repo.Selected, rank}
+			case s.strategy() == SelectAny:
+				if repo.Selected && !current.selected {
+					decisions[repo.ID] = decision{true, rank}
+				}
+			case rank < current.rank:
+				decisions[repo.ID] = decision{repo.Selected, rank}
+			}
+		}
+	}
+
+	return decisions
+}
+
+// validate verifies that the prioritized policy types are in the vocabulary
+func (s *Selection) validate(vocabulary []string) error {
+	if s == nil {
+		return nil
+	}
+
+	known := make(map[string]bool, len(vocabulary))
+	for _, t := range vocabulary {
+		known[t] = true
+	}
+
+	for _, t := range s.TypePriority {
+		if !known[t] {
+			return errors.Errorf("selection type-priority '%s' is not one of the known types: %s",
+				t, strings.Join(vocabulary, ", "))
+		}
+	}
+
+	return nil
+}
+
+// withSelection decides which repositories are selected, consistently wherever they appear in
+// the requirements.  If the selection allows only one selected repository per one-of group,
+// selected repositories are considered from highest priority to lowest, and deselected if they
+// share a one-of group with a repository that remains selected.
+func (r *Requirements) withSelection(s *Selection, policies []Policy) *Requirements {
+	decisions := s.decide(policies)
+
+	for i := range r.Required {
+		r.Required[i].Selected = decisions[r.Required[i].ID].selected
+	}
+
+	for i := range r.OneOf {
+		for j := range r.OneOf[i] {
+			r.OneOf[i][j].Selected = decisions[r.OneOf[i][j].ID].selected
+		}
+	}
+
+	for i := range r.Optional {
+		r.Optional[i].Selected = decisions[r.Optional[i].ID].selected
+	}
+
+	if s == nil || !s.OnePerGroup {
+		return r
+	}
+
+	var selected []string
+	for id, d := range decisions {
+		if d.selected && repoListsContain(r.OneOf, Repository{ID: id}) {
+			selected = append(selected, id)
+		}
+	}
+
+	sort.Slice(selected, func(i, j int) bool {
+		if decisions[selected[i]].rank != decisions[selected[j]].rank {
+			return decisions[selected[i]].rank < decisions[selected[j]].rank
+		}
+		return selected[i] < selected[j]
+	})
+
+	decided := make([]bool, len(r.OneOf))
+	for _, id := range selected {
+		keep := true
+		for i, group := range r.OneOf {
+			if decided[i] && repoListContains(group, Repository{ID: id}) {
+				keep = false
+			}
+		}
+
+		for i := range r.OneOf {
+			for j := range r.OneOf[i] {
+				if r.OneOf[i][j].ID != id {
+					continue
+				}
+				r.OneOf[i][j].Selected = keep
+				decided[i] = decided[i] || keep
+			}
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,120 @@
+package rule_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+func TestSelection(t *testing.T) {
+
+	// (a* or b) from a funder, (a or b*) from an institution, and (c* or d*) from another funder
+	policies := []rule.Policy{{
+		ID:   "funder1",
+		Type: "funder",
+		Repositories: []rule.Repository{
+			{ID: "a", Selected: true},
+			{ID: "b", Selected: false},
+		},
+	}, {
+		ID:   "institution",
+		Type: "institution",
+		Repositories: []rule.Repository{
+			{ID: "a", Selected: false},
+			{ID: "b", Selected: true},
+		},
+	}, {
+		ID:   "funder2",
+		Type: "funder",
+		Repositories: []rule.Repository{
+			{ID: "c", Selected: true},
+			{ID: "d", Selected: true},
+		},
+	}}
+
+	cases := []struct {
+		testName  string
+		selection *rule.Selection
+		expected  []string
+	}{{
+		testName: "default",
+		expected: []string{"a", "b", "c", "d"},
+	}, {
+		testName:  "any selected",
+		selection: &rule.Selection{Strategy: rule.SelectAny},
+		expected:  []string{"a", "b", "c", "d"},
+	}, {
+		testName:  "rule order",
+		selection: &rule.Selection{Strategy: rule.SelectRuleOrder},
+		expected:  []string{"a", "c", "d"},
+	}, {
+		testName:  "institution over funder",
+		selection: &rule.Selection{Strategy: rule.SelectTypePriority},
+		expected:  []string{"b", "c", "d"},
+	}, {
+		testName:  "funder over institution",
+		selection: &rule.Selection{Strategy: rule.SelectTypePriority, TypePriority: []string{"funder", "institution"}},
+		expected:  []string{"a", "c", "d"},
+	}, {
+		testName:  "any selected, one per group",
+		selection: &rule.Selection{OnePerGroup: true},
+		expected:  []string{"a", "c"},
+	}, {
+		testName:  "institution over funder, one per group",
+		selection: &rule.Selection{Strategy: rule.SelectTypePriority, OnePerGroup: true},
+		expected:  []string{"b", "c"},
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			rules := &rule.DSL{Selection: c.selection}
+			requirements := rules.AnalyzeRequirements(policies)
+
+			selected := []string{}
+			for _, group := range requirements.OneOf {
+				for _, repo := range group {
+					if repo.Selected {
+						selected = append(selected, repo.ID)
+					}
+				}
+			}
+			sort.Strings(selected)
+
+			// Both one-of groups are kept, so the expected repositories appear once each
+			if diffs := deep.Equal(selected, c.expected); len(diffs) > 0 {
+				t.Fatalf("did not select expected repositories: %v\n%+v", diffs, requirements)
+			}
+		})
+	}
+}
+
+func TestSelectionConsistent(t *testing.T) {
+
+	// b appears in two groups, so must be selected in both or neither
+	policies := []rule.Policy{{
+		Repositories: []rule.Repository{
+			{ID: "a", Selected: true},
+			{ID: "b", Selected: false},
+		},
+	}, {
+		Repositories: []rule.Repository{
+			{ID: "b", Selected: true},
+			{ID: "c", Selected: false},
+		},
+	}}
+
+	rules := &rule.DSL{Selection: &rule.Selection{Strategy: rule.SelectRuleOrder, OnePerGroup: true}}
+	requirements := rules.AnalyzeRequirements(policies)
+
+	expected := [][]rule.Repository{
+		{{ID: "a", Selected: true}, {ID: "b", Selected: false}},
+		{{ID: "b", Selected: false}, {ID: "c", Selected: false}},
+	}
+
+	if diffs := deep.Equal(requirements.OneOf, expected); len(diffs) > 0 {
+		t.Fatalf("did not get expected selection: %v", diffs)
+	}
+}
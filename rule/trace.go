@@ -0,0 +1,129 @@
+package rule
+
+// Trace records the evaluation of policy rules in a context, in order to explain their results:
+// the values variables resolved to, and for each policy a rule produced, whether its conditions
+// held and whether it applies.  A trace is not safe for concurrent use.
+type Trace struct {
+	Variables []VariableTrace `json:"variables"`
+	Rules     []RuleTrace     `json:"rules"`
+	current   *RuleTrace      // rule being evaluated, if any
+	repo      string          // repository whose conditions are being evaluated, if any
+}
+
+// VariableTrace records the values a variable resolved to.  Pinned variables may resolve to
+// different values for different policies, so the same variable may be traced more than once.
+type VariableTrace struct {
+	Name   string   `json:"name"`
+	Values []string `json:"values"`
+}
+
+// RuleTrace records the evaluation of a rule for a single policy.  If the rule's policy ID is a
+// variable that resolved to no policies, the trace has that variable as its policy ID.
+type RuleTrace struct {
+	RuleID     string           `json:"rule-id"`
+	PolicyID   string           `json:"policy-id"`
+	Conditions []ConditionTrace `json:"conditions,omitempty"`
+	InEffect   *bool            `json:"in-effect,omitempty"` // whether the effective dates hold, if evaluated
+	Applies    bool             `json:"applies"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// ConditionTrace records the result of a condition of a rule, or of one of its repositories.
+// Conditions are evaluated only until one is false, so later ones may not be traced.
+type ConditionTrace struct {
+	Condition  Condition `json:"condition"`
+	Repository string    `json:"repository-id,omitempty"` // set if it is a condition of the repository
+	Result     bool      `json:"result"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// traceOf returns the trace of the context variables are resolved in, or nil if it is not traced
+func traceOf(variables VariableResolver) *Trace {
+	if context, ok := variables.(*Context); ok {
+		return context.Trace
+	}
+	return nil
+}
+
+func (t *Trace) variable(name string, values []string) {
+	if t == nil {
+		return
+	}
+
+	for _, v := range t.Variables {
+		if v.Name == name && equalStrings(v.Values, values) {
+			return
+		}
+	}
+
+	t.Variables = append(t.Variables, VariableTrace{Name: name, Values: values})
+}
+
+// begin starts the trace of a rule evaluated for the given policy ID
+func (t *Trace) begin(p Policy, policyID string) {
+	if t == nil {
+		return
+	}
+
+	t.current = &RuleTrace{RuleID: p.RuleID, PolicyID: policyID}
+}
+
+// end completes the trace of the rule being evaluated
+func (t *Trace) end(applies bool, err error) {
+	if t == nil || t.current == nil {
+		return
+	}
+
+	t.current.Applies = applies
+	if err != nil {
+		t.current.Error = err.Error()
+	}
+
+	t.Rules = append(t.Rules, *t.current)
+	t.current = nil
+}
+
+// repository notes that the conditions evaluated next are those of the given repository, or of
+// the rule itself if empty
+func (t *Trace) repository(id string) {
+	if t == nil {
+		return
+	}
+
+	t.repo = id
+}
+
+func (t *Trace) condition(c Condition, result bool, err error) {
+	if t == nil || t.current == nil {
+		return
+	}
+
+	traced := ConditionTrace{Condition: c, Repository: t.repo, Result: result}
+	if err != nil {
+		traced.Error = err.Error()
+	}
+
+	t.current.Conditions = append(t.current.Conditions, traced)
+}
+
+func (t *Trace) inEffect(inEffect bool) {
+	if t == nil || t.current == nil {
+		return
+	}
+
+	t.current.InEffect = &inEffect
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,103 @@
+package rule_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+func TestTrace(t *testing.T) {
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-rules": [
+			{
+				"rule-id": "funders",
+				"policy-id": "${submission.grants.primaryFunder.policy}",
+				"type": "funder",
+				"repositories": [{"repository-id": "${policy.repositories}"}]
+			},
+			{
+				"rule-id": "jhu",
+				"policy-id": "/policies/jhu",
+				"type": "institution",
+				"conditions": [{"endsWith": {"@jhu.edu": "${header.Eppn}"}}],
+				"repositories": [
+					{"repository-id": "/repositories/ir"},
+					{"repository-id": "/repositories/faculty", "conditions": [{"equals": {"faculty": "${header.Affiliation}"}}]}
+				]
+			},
+			{
+				"rule-id": "harvard",
+				"policy-id": "/policies/harvard",
+				"type": "institution",
+				"conditions": [{"endsWith": {"@harvard.edu": "${header.Eppn}"}}],
+				"repositories": [{"repository-id": "/repositories/dash"}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("rules failed validation %+v", err)
+	}
+
+	trace := &rule.Trace{}
+	policies, err := rules.Resolve(&rule.Context{
+		Submission: map[string]interface{}{"grants": []interface{}{}},
+		Headers: map[string][]string{
+			"Eppn":        {"someone@jhu.edu"},
+			"Affiliation": {"staff"},
+		},
+		Trace: trace,
+	})
+	if err != nil {
+		t.Fatalf("Error resolving policies: %+v", err)
+	}
+
+	if len(policies) != 1 || policies[0].ID != "/policies/jhu" {
+		t.Fatalf("Did not get expected policies: %+v", policies)
+	}
+
+	applies := true
+	expected := []rule.RuleTrace{{
+		RuleID:   "funders",
+		PolicyID: "${submission.grants.primaryFunder.policy}",
+	}, {
+		RuleID:   "jhu",
+		PolicyID: "/policies/jhu",
+		Conditions: []rule.ConditionTrace{{
+			Condition:  rule.Condition{"equals": map[string]interface{}{"faculty": "${header.Affiliation}"}},
+			Repository: "/repositories/faculty",
+			Result:     false,
+		}, {
+			Condition: rule.Condition{"endsWith": map[string]interface{}{"@jhu.edu": "${header.Eppn}"}},
+			Result:    true,
+		}},
+		InEffect: &applies,
+		Applies:  true,
+	}, {
+		RuleID:   "harvard",
+		PolicyID: "/policies/harvard",
+		Conditions: []rule.ConditionTrace{{
+			Condition: rule.Condition{"endsWith": map[string]interface{}{"@harvard.edu": "${header.Eppn}"}},
+			Result:    false,
+		}},
+	}}
+
+	if diffs := deep.Equal(trace.Rules, expected); len(diffs) > 0 {
+		t.Fatalf("Did not get expected rule traces: %s", strings.Join(diffs, "\n"))
+	}
+
+	traced := make(map[string][]string)
+	for _, v := range trace.Variables {
+		traced[v.Name] = v.Values
+	}
+
+	if diffs := deep.Equal(traced["${header.Eppn}"], []string{"someone@jhu.edu"}); len(diffs) > 0 {
+		t.Fatalf("Did not trace header: %s", strings.Join(diffs, "\n"))
+	}
+
+	if diffs := deep.Equal(traced["${submission.grants.primaryFunder.policy}"], []string{}); len(diffs) > 0 {
+		t.Fatalf("Did not trace policy ID variable: %s", strings.Join(diffs, "\n"))
+	}
+}
@@ -3,7 +3,6 @@
 package rule
 
 import (
-	"bytes"
 	"encoding/json"
 	"strings"
 
@@ -12,6 +11,23 @@ import (
 	"github.com/qri-io/jsonschema"
 )
 
+// Policy rules DSL schemas supported by this implementation, identified by
+// the $id they declare (and which rules documents reference in $schema)
+const (
+	SchemaV1 = "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_1.0.json"
+	SchemaV2 = "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json"
+
+	// LatestSchema is the newest supported schema; rules documents can be
+	// migrated to it via Migrate
+	LatestSchema = SchemaV2
+)
+
+// schemaFiles maps each supported schema to its file in the schemas directory
+var schemaFiles = map[string]string{
+	SchemaV1: "policy_config_1.0.json",
+	SchemaV2: "policy_config_2.0.json",
+}
+
 type validationErrors []jsonschema.ValError
 
 func (v validationErrors) Error() string {
@@ -25,35 +41,73 @@ func (v validationErrors) Error() string {
 }
 
 // Validate validates a serialized policy rules document with respect to
-// the implementation's expected JSON schema, and attempts to parse it.
+// the schema it declares in $schema, and attempts to parse it.  Any included
+// documents are validated and parsed as well, relative to the working directory.
 func Validate(rulesDoc []byte) (*DSL, error) {
-	box := packr.New("myBox", "../schemas")
-	file, err := box.Open("policy_config_1.0.json")
+	return (&loader{}).load("", rulesDoc)
+}
+
+// validateSchema validates a serialized rules document against the schema it declares
+func validateSchema(rulesDoc []byte) error {
+	schema, err := declaredSchema(rulesDoc)
 	if err != nil {
-		// Will only happen if internal schema is misnamed
-		return nil, errors.Wrapf(err, "could not read schema")
+		return err
 	}
 
-	rs := &jsonschema.RootSchema{}
-	err = json.NewDecoder(file).Decode(rs)
+	rs, err := loadSchema(schema)
 	if err != nil {
-		// WIll only happen if internal schema is malformed
-		return nil, errors.Wrapf(err, "could not parse schema")
+		return err
 	}
 
 	valErrors, err := rs.ValidateBytes(rulesDoc)
 	if err != nil {
-		return nil, errors.Wrapf(err, "could not parse rules doc")
+		return errors.Wrapf(err, "could not parse rules doc")
 	}
 
 	if len(valErrors) > 0 {
-		return nil, validationErrors(valErrors)
+		return validationErrors(valErrors)
+	}
+
+	return nil
+}
+
+// declaredSchema returns the $schema declared by a serialized rules document
+func declaredSchema(rulesDoc []byte) (string, error) {
+	var declaration struct {
+		Schema string `json:"$schema"`
+	}
+
+	if err := json.Unmarshal(rulesDoc, &declaration); err != nil {
+		return "", errors.Wrapf(err, "could not parse rules doc")
+	}
+
+	if declaration.Schema == "" {
+		return "", errors.New("rules doc does not declare a $schema")
+	}
+
+	return declaration.Schema, nil
+}
+
+// loadSchema loads and parses the given supported schema
+func loadSchema(schema string) (*jsonschema.RootSchema, error) {
+	filename, ok := schemaFiles[schema]
+	if !ok {
+		return nil, errors.Errorf("unsupported schema %s", schema)
 	}
 
-	// Serialize just to defend against programmer error
-	rules := DSL{}
-	decoder := json.NewDecoder(bytes.NewReader(rulesDoc))
-	decoder.DisallowUnknownFields()
+	box := packr.New("myBox", "../schemas")
+	file, err := box.Open(filename)
+	if err != nil {
+		// Will only happen if internal schema is misnamed
+		return nil, errors.Wrapf(err, "could not read schema")
+	}
+
+	rs := &jsonschema.RootSchema{}
+	err = json.NewDecoder(file).Decode(rs)
+	if err != nil {
+		// WIll only happen if internal schema is malformed
+		return nil, errors.Wrapf(err, "could not parse schema")
+	}
 
-	return &rules, decoder.Decode(&rules)
+	return rs, nil
 }
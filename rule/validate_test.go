@@ -2,18 +2,25 @@ package rule_test
 
 import (
 	"io/ioutil"
+	"strings"
 	"testing"
 
+	"github.com/go-test/deep"
 	"github.com/oa-pass/pass-policy-service/rule"
 )
 
-// A known-good document should validate just fine
+// Known-good documents should validate just fine, whatever supported schema they declare
 func TestValidateGoodData(t *testing.T) {
-	content, _ := ioutil.ReadFile("testdata/good.json")
+	for _, file := range []string{"testdata/good.json", "testdata/good_2.0.json"} {
+		file := file
+		t.Run(file, func(t *testing.T) {
+			content, _ := ioutil.ReadFile(file)
 
-	_, err := rule.Validate(content)
-	if err != nil {
-		t.Fatalf("Validation failed: %+v", err)
+			_, err := rule.Validate(content)
+			if err != nil {
+				t.Fatalf("Validation failed: %+v", err)
+			}
+		})
 	}
 }
 
@@ -24,6 +31,113 @@ func TestValidateBadData(t *testing.T) {
 	cases := map[string][]byte{
 		"schemaInvalid": invalidDoc,
 		"badJSON":       []byte(`{moo`),
+		"noSchema":      []byte(`{"policy-rules": []}`),
+		"unknownSchema": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_0.1.json",
+			"policy-rules": []
+		}`),
+		"groupWithoutOneOf": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"repositories": [{"repository-id": "a", "requirement": "required", "group": "g"}]
+			}]
+		}`),
+		"undeclaredPolicyType": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "publisher",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`),
+		"typeNotInVocabulary": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-types": ["publisher", "journal"],
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`),
+		"badEffectiveDate": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"effective-from": "2020-13-01",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`),
+		"badReferenceDate": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"reference-date": "Spring 2020",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"effective-from": "2020-07-01",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`),
+		"emptyEffectiveRange": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"effective-from": "2020-07-01",
+				"effective-until": "2020-07-01",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`),
+		"unknownRequirement": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"repositories": [{"repository-id": "a", "requirement": "mandatory"}]
+			}]
+		}`),
+		"duplicateRuleID": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"rule-id": "nih",
+				"policy-id": "policy",
+				"type": "funder",
+				"repositories": [{"repository-id": "a"}]
+			}, {
+				"rule-id": "nih",
+				"policy-id": "another-policy",
+				"type": "funder",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`),
+		"badConstraint": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"repositories": [{"repository-id": "a", "constraints": {"max-embargo-months": "twelve"}}]
+			}]
+		}`),
+		"badSelectionStrategy": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"selection": {"strategy": "coin-toss"},
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`),
+		"unknownPriorityType": []byte(`{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"selection": {"strategy": "type-priority", "type-priority": ["publisher", "funder"]},
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "funder",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`),
 	}
 
 	for name, content := range cases {
@@ -38,3 +152,76 @@ func TestValidateBadData(t *testing.T) {
 	}
 
 }
+
+func TestPolicyTypes(t *testing.T) {
+	cases := []struct {
+		testName string
+		doc      string
+		expected []string
+	}{{
+		testName: "default",
+		doc: `{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-rules": []
+		}`,
+		expected: []string{"funder", "institution"},
+	}, {
+		testName: "declared",
+		doc: `{
+			"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+			"policy-types": ["publisher", "funder", "department"],
+			"policy-rules": [{
+				"policy-id": "policy",
+				"type": "department",
+				"repositories": [{"repository-id": "a"}]
+			}]
+		}`,
+		expected: []string{"publisher", "funder", "department"},
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			rules, err := rule.Validate([]byte(c.doc))
+			if err != nil {
+				t.Fatalf("Validation failed: %+v", err)
+			}
+
+			diffs := deep.Equal(rules.PolicyTypes(), c.expected)
+			if len(diffs) > 0 {
+				t.Fatalf("Did not get expected policy types: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
+// Rules without a reference date use the one declared by their document
+func TestReferenceDate(t *testing.T) {
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"reference-date": "${submission.submittedDate}",
+		"policy-rules": [{
+			"policy-id": "one",
+			"type": "funder",
+			"effective-from": "2020-07-01",
+			"repositories": [{"repository-id": "a"}]
+		}, {
+			"policy-id": "two",
+			"type": "funder",
+			"effective-until": "2021-07-01T00:00:00Z",
+			"reference-date": "${now}",
+			"repositories": [{"repository-id": "a"}]
+		}]
+	}`))
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	if rules.Policies[0].ReferenceDate != "${submission.submittedDate}" {
+		t.Fatalf("Expected document's reference date, got %s", rules.Policies[0].ReferenceDate)
+	}
+
+	if rules.Policies[1].ReferenceDate != "${now}" {
+		t.Fatalf("Expected rule's reference date, got %s", rules.Policies[1].ReferenceDate)
+	}
+}
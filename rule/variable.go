@@ -1,9 +1,15 @@
 package rule
 
 import (
+	"regexp"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
+// embeddedVariable matches variables within text, e.g. ${grants.awardNumber} in "grant ${grants.awardNumber}"
+var embeddedVariable = regexp.MustCompile(`\$\{[^${}]+\}`)
+
 // variable encodes a variable for interpolation, eg. ${foo.bar.baz}, or a
 // segment of one, e.g. ${foo.bar} of ${foo.bar.baz}
 type variable struct {
@@ -36,6 +42,29 @@ func IsVariable(text string) bool {
 		strings.HasSuffix(text, "}")
 }
 
+// Interpolate replaces each variable embedded in a string of text with its value.  Variables that
+// resolve to multiple values are replaced with a comma-separated list of them, and variables that
+// resolve to nothing are replaced with the empty string.
+func Interpolate(text string, variables VariableResolver) (string, error) {
+	var err error
+
+	interpolated := embeddedVariable.ReplaceAllStringFunc(text, func(vari string) string {
+		if err != nil {
+			return ""
+		}
+
+		vals, resolveErr := variables.Resolve(vari)
+		if resolveErr != nil {
+			err = errors.Wrapf(resolveErr, "could not resolve %s", vari)
+			return ""
+		}
+
+		return strings.Join(vals, ", ")
+	})
+
+	return interpolated, err
+}
+
 // toVariable creates a Variable from a string like '${foo.bar.baz}' (dollar sign and braces required)
 func toVariable(text string) (variable, bool) {
 	if !IsVariable(text) {
@@ -67,3 +67,43 @@ func TestShift(t *testing.T) {
 		t.Fatalf("Got %d segments, expected %d", i+1, numSegments)
 	}
 }
+
+func TestInterpolate(t *testing.T) {
+	variables := &Context{
+		Headers: map[string][]string{
+			"Single": {"a"},
+			"Multi":  {"b", "c"},
+		},
+	}
+
+	cases := []struct {
+		testName string
+		text     string
+		expected string
+	}{
+		{"noVariables", "nothing to see here", "nothing to see here"},
+		{"single", "value is ${header.Single}.", "value is a."},
+		{"multi", "values are ${header.Multi}", "values are b, c"},
+		{"several", "${header.Single} and ${header.Multi}", "a and b, c"},
+		{"noValue", "value is '${header.Missing}'", "value is ''"},
+		{"unterminated", "value is ${header.Single", "value is ${header.Single"},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			interpolated, err := Interpolate(c.text, variables)
+			if err != nil {
+				t.Fatalf("Error interpolating %s: %+v", c.text, err)
+			}
+
+			if interpolated != c.expected {
+				t.Fatalf("Expected '%s', got '%s'", c.expected, interpolated)
+			}
+		})
+	}
+
+	if _, err := Interpolate("bad ${submission.foo}", &Context{}); err == nil {
+		t.Fatalf("Expected an error interpolating an unresolvable variable")
+	}
+}
@@ -0,0 +1,127 @@
+package rule
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// isYAML determines if a rules document is written in YAML rather than JSON.  Documents with a
+// .yaml, .yml, or .json extension are taken at their word.  Otherwise, JSON rules documents are
+// objects, so anything that doesn't start with '{' is presumed to be YAML.
+func isYAML(path string, rulesDoc []byte) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	case ".json":
+		return false
+	}
+
+	content := bytes.TrimSpace(rulesDoc)
+	return len(content) > 0 && content[0] != '{'
+}
+
+// yamlToJSON converts a YAML rules document to JSON, so that it can be validated against the
+// schema.  The parsed YAML is returned as well, in order to locate errors within it.
+func yamlToJSON(rulesDoc []byte) ([]byte, *yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(rulesDoc, &root); err != nil {
+		return nil, nil, errors.Wrapf(err, "could not parse YAML rules doc")
+	}
+
+	var content interface{}
+	if err := root.Decode(&content); err != nil {
+		return nil, nil, errors.Wrapf(err, "could not parse YAML rules doc")
+	}
+
+	converted, err := json.Marshal(content)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "could not convert YAML rules doc to JSON")
+	}
+
+	return converted, &root, nil
+}
+
+// locator describes where JSON pointers (e.g. /policy-rules/0) are found in a rules document.
+// For YAML documents, that includes a line number.
+type locator struct {
+	yaml *yaml.Node // root of a YAML document, or nil if the document is JSON
+}
+
+// locate describes the location of a JSON pointer within the document
+func (l locator) locate(pointer string) string {
+	if l.yaml == nil {
+		return pointer
+	}
+
+	return fmt.Sprintf("line %d %s", l.line(pointer), pointer)
+}
+
+// locateErrors annotates schema validation errors with their location in the document
+func (l locator) locateErrors(err error) error {
+	valErrors, ok := err.(validationErrors)
+	if !ok || l.yaml == nil {
+		return err
+	}
+
+	return yamlValidationErrors{valErrors, l}
+}
+
+// line finds the line of the YAML node at the given JSON pointer.  If there is no such node,
+// it returns the line of the closest node that encloses where it would be.
+func (l locator) line(pointer string) int {
+	node := l.yaml
+	if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+		node = node.Content[0]
+	}
+
+	line := node.Line
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == token {
+					line = node.Content[i].Line
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(node.Content) {
+				next = node.Content[i]
+				line = next.Line
+			}
+		}
+
+		if next == nil {
+			break
+		}
+		node = next
+	}
+
+	return line
+}
+
+// yamlValidationErrors are schema validation errors in a YAML document
+type yamlValidationErrors struct {
+	errors validationErrors
+	locator
+}
+
+func (y yamlValidationErrors) Error() string {
+	var b strings.Builder
+	for _, e := range y.errors {
+		fmt.Fprintf(&b, "line %d: %s\n", y.line(e.PropertyPath), e.Error())
+	}
+
+	return b.String()
+}
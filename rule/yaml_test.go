@@ -0,0 +1,92 @@
+package rule_test
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+// YAML rules documents are equivalent to their JSON counterparts
+func TestValidateYAML(t *testing.T) {
+	expected, err := rule.ValidateFile("testdata/good_2.0.json")
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	yamlDoc, _ := ioutil.ReadFile("testdata/good_2.0.yaml")
+
+	// YAML is detected by file extension, or else by content
+	fromFile, err := rule.ValidateFile("testdata/good_2.0.yaml")
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	fromContent, err := rule.Validate(yamlDoc)
+	if err != nil {
+		t.Fatalf("Validation failed: %+v", err)
+	}
+
+	for _, rules := range []*rule.DSL{fromFile, fromContent} {
+		diffs := deep.Equal(rules, expected)
+		if len(diffs) > 0 {
+			t.Fatalf("YAML rules differ from JSON rules: %s", strings.Join(diffs, "\n"))
+		}
+	}
+}
+
+// Errors in YAML documents refer to YAML line numbers
+func TestValidateBadYAML(t *testing.T) {
+	cases := []struct {
+		testName    string
+		doc         string
+		errContains string
+	}{{
+		testName: "schemaInvalid",
+		doc: `$schema: https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json
+policy-rules:
+  - policy-id: policy
+    type: funder
+    repositories:
+      - repository-id: a
+        requirement: mandatory
+`,
+		errContains: "line 6: /policy-rules/0/repositories/0",
+	}, {
+		testName: "badEffectiveDate",
+		doc: `$schema: https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json
+policy-rules:
+  - policy-id: policy
+    type: funder
+    repositories: [{repository-id: a}]
+
+  # Effective dates are in the wrong order
+  - policy-id: policy
+    type: funder
+    effective-from: 2020-07-01
+    effective-until: 2020-01-01
+    repositories: [{repository-id: a}]
+`,
+		errContains: "line 8 /policy-rules/1",
+	}, {
+		testName:    "malformed",
+		doc:         "$schema: [unterminated",
+		errContains: "could not parse YAML",
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.testName, func(t *testing.T) {
+			_, err := rule.Validate([]byte(c.doc))
+			if err == nil {
+				t.Fatalf("Validation should have failed!")
+			}
+
+			if !strings.Contains(err.Error(), c.errContains) {
+				t.Fatalf("Expected error to mention '%s', got %s", c.errContains, err)
+			}
+		})
+	}
+}
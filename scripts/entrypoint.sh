@@ -4,4 +4,4 @@ printf "\n**** Begin Environment Variable Dump ****\n\n"
 printenv | sort
 printf "\n**** End Environment Variable Dump ****\n\n"
 
-./pass-policy-service serve ${POLICY_FILE}
+exec ./pass-policy-service serve ${POLICY_FILE}
@@ -0,0 +1,169 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+const (
+	ndjsonMediaType     = "application/x-ndjson"
+	defaultBatchWorkers = 8
+)
+
+// BatchRequest is the body of a request to the batch endpoint:  the URIs of the submissions to
+// find policies for, and the request headers to evaluate them as if they were submitted with.
+// Giving headers requires administrative credentials.  If there are no headers, the headers of
+// the batch request itself are used.
+type BatchRequest struct {
+	Submissions []string    `json:"submissions"`
+	Headers     http.Header `json:"headers,omitempty"`
+}
+
+// BatchResult is a line of a batch endpoint response:  the policies of a submission, as the
+// policies endpoint would report them, or the error that prevented finding them
+type BatchResult struct {
+	Submission string         `json:"submission"`
+	Policies   []PolicyResult `json:"policies"`
+	Error      string         `json:"error,omitempty"`
+}
+
+type batchRequest struct {
+	*PolicyService
+	req  *http.Request
+	resp http.ResponseWriter
+}
+
+// RequestBatch finds the policies of a batch of submissions, streaming the result for each
+// as a line of newline-delimited JSON, as soon as it is known
+func (s *PolicyService) RequestBatch(w http.ResponseWriter, r *http.Request) {
+	s = s.current()
+	s.doRequest(&batchRequest{s, r, w}, w, r)
+}
+
+func (b *batchRequest) handleGet() {
+	http.Error(b.resp, "Method not allowed", http.StatusMethodNotAllowed)
+}
+
+func (b *batchRequest) handlePost() {
+	if !isJSON(b.req) {
+		http.Error(b.resp,
+			"expected media type application/json, instead got "+b.req.Header.Get("Content-Type"),
+			http.StatusBadRequest)
+		return
+	}
+
+	var batch BatchRequest
+	if err := json.NewDecoder(b.req.Body).Decode(&batch); err != nil {
+		http.Error(b.resp, "Could not parse batch request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Evaluating submissions as if somebody else requested them is for administrators only
+	headers := b.req.Header
+	if len(batch.Headers) > 0 {
+		if !b.authorizeAdmin(b.resp, b.req, "evaluating a batch with the given headers") {
+			return
+		}
+		headers = make(http.Header, len(batch.Headers))
+		for name, values := range batch.Headers {
+			headers[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+
+	b.resp.Header().Set("Content-Type", ndjsonMediaType)
+
+	encoder := json.NewEncoder(b.resp)
+	flusher, _ := b.resp.(http.Flusher)
+
+	// Stop evaluating submissions if the client goes away, or the results can't be sent
+	ctx, cancel := context.WithCancel(b.req.Context())
+	defer cancel()
+
+	for result := range b.evaluate(ctx, batch.Submissions, headers) {
+		if err := encoder.Encode(result); err != nil {
+			log.Printf("error encoding JSON response: %s", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// evaluate finds the policies of each submission using a bounded number of workers, all
+// sharing a cache of the entities they fetch.  Results are sent as they are found, so are
+// not necessarily in the order of the submissions.  Once the given context is done, no more
+// submissions are evaluated, and results not yet received are discarded.
+func (b *batchRequest) evaluate(ctx context.Context, submissions []string, headers http.Header) <-chan BatchResult {
+	workers := b.BatchWorkers
+	if workers <= 0 {
+		workers = defaultBatchWorkers
+	}
+	if workers > len(submissions) {
+		workers = len(submissions)
+	}
+
+	fetcher := newCachingFetcher(b.Fetcher, b.Replace, b.Metrics)
+	queue := make(chan string)
+	results := make(chan BatchResult)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for uri := range queue {
+				select {
+				case results <- b.evaluateOne(uri, headers, fetcher):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+	feed:
+		for _, uri := range submissions {
+			select {
+			case queue <- uri:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(queue)
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+func (b *batchRequest) evaluateOne(uri string, headers http.Header, fetcher rule.PassEntityFetcher) BatchResult {
+	result := BatchResult{Submission: uri}
+
+	privateSubmissionURI, ok := b.Replace.PublicWithPrivate(uri)
+	if !ok {
+		result.Error = fmt.Sprintf("submission URI %s does not have the expected PASS baseURI", uri)
+		return result
+	}
+
+	context := b.context(submission{uri: privateSubmissionURI}, headers)
+	context.PassClient = fetcher
+
+	policies, err := b.Rules.Resolve(context)
+	if err != nil {
+		log.Printf("Error resolving policies of %s: %+v", uri, err)
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Policies = b.policyResults(policies)
+	return result
+}
@@ -0,0 +1,271 @@
+package web_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/oa-pass/pass-policy-service/web"
+)
+
+// countingFetcher counts how many times each entity is fetched
+type countingFetcher struct {
+	testFetcher
+	mutex   sync.Mutex
+	fetches map[string]int
+}
+
+func (f *countingFetcher) FetchEntity(url string, entityPointer interface{}) error {
+	f.mutex.Lock()
+	url, _ = baseURIs.PublicWithPrivate(url)
+	f.fetches[url]++
+	f.mutex.Unlock()
+
+	return f.testFetcher.FetchEntity(url, entityPointer)
+}
+
+func TestBatchEndpoint(t *testing.T) {
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-rules": [
+			{
+				"rule-id": "funders",
+				"policy-id": "${submission.grants.primaryFunder.policy}",
+				"type": "funder",
+				"repositories": [{"repository-id": "${policy.repositories}"}]
+			},
+			{
+				"rule-id": "jhu",
+				"policy-id": "/policies/institution",
+				"type": "institution",
+				"conditions": [{"endsWith": {"@johnshopkins.edu": "${header.Ajp_eppn}"}}],
+				"repositories": [{"repository-id": "/repositories/ir"}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("rules failed validation %+v", err)
+	}
+
+	// Two submissions with the same grant
+	content := testFetcher{}
+	for uri, entity := range fedora {
+		content[uri] = entity
+	}
+	content[privateBaseURI+"/submissions/2"] = fedora[privateBaseURI+"/submissions/1"]
+
+	fetcher := &countingFetcher{testFetcher: content, fetches: map[string]int{}}
+
+	service := web.PolicyService{
+		Rules:        rules,
+		Fetcher:      fetcher,
+		Replace:      baseURIs,
+		BatchWorkers: 2,
+		Admin:        &web.Credentials{Username: "admin", Password: "secret"},
+	}
+
+	body, _ := json.Marshal(web.BatchRequest{
+		Submissions: []string{
+			submissionURI,
+			publicBaseURI + "/submissions/2",
+			publicBaseURI + "/submissions/missing",
+		},
+		Headers: http.Header{"ajp_eppn": {"someone@johnshopkins.edu"}},
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	req.SetBasicAuth("admin", "secret")
+
+	resp := httptest.NewRecorder()
+	service.RequestBatch(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if resp.Header().Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("Got content type %s", resp.Header().Get("Content-Type"))
+	}
+
+	var results []web.BatchResult
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		var result web.BatchResult
+		if err := json.Unmarshal(lines.Bytes(), &result); err != nil {
+			t.Fatalf("Could not parse line %s: %+v", lines.Text(), err)
+		}
+		results = append(results, result)
+	}
+
+	// Results arrive in whatever order they are found
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Submission < results[j].Submission
+	})
+
+	policies := []web.PolicyResult{
+		{ID: publicBaseURI + "/policies/nih", Type: "funder", RuleID: "funders"},
+		{ID: publicBaseURI + "/policies/institution", Type: "institution", RuleID: "jhu"},
+	}
+
+	if diffs := deep.Equal(results[0:2], []web.BatchResult{
+		{Submission: submissionURI, Policies: policies},
+		{Submission: publicBaseURI + "/submissions/2", Policies: policies},
+	}); len(diffs) > 0 {
+		t.Fatalf("Did not get expected results: %s", strings.Join(diffs, "\n"))
+	}
+
+	if results[2].Submission != publicBaseURI+"/submissions/missing" || results[2].Error == "" {
+		t.Fatalf("Expected an error for the missing submission, got %+v", results[2])
+	}
+
+	// Entities common to the submissions are fetched just once
+	for _, uri := range []string{"/grants/1", "/funders/nih", "/policies/nih"} {
+		if fetcher.fetches[privateBaseURI+uri] != 1 {
+			t.Errorf("%s fetched %d times", uri, fetcher.fetches[privateBaseURI+uri])
+		}
+	}
+}
+
+// Evaluating a batch as if somebody else requested it requires administrative credentials
+func TestBatchHeadersAuthorization(t *testing.T) {
+	service := repositoriesService(t, testFetcher(fedora))
+
+	cases := []struct {
+		name     string
+		headers  http.Header
+		admin    *web.Credentials
+		username string
+		password string
+		status   int
+	}{{
+		name:   "own headers",
+		status: http.StatusOK,
+	}, {
+		name:    "disabled",
+		headers: http.Header{"Ajp_eppn": {"someone@johnshopkins.edu"}},
+		status:  http.StatusForbidden,
+	}, {
+		name:    "no credentials",
+		headers: http.Header{"Ajp_eppn": {"someone@johnshopkins.edu"}},
+		admin:   &web.Credentials{Username: "admin", Password: "secret"},
+		status:  http.StatusUnauthorized,
+	}, {
+		name:     "wrong credentials",
+		headers:  http.Header{"Ajp_eppn": {"someone@johnshopkins.edu"}},
+		admin:    &web.Credentials{Username: "admin", Password: "secret"},
+		username: "admin",
+		password: "guess",
+		status:   http.StatusUnauthorized,
+	}, {
+		name:     "authenticated",
+		headers:  http.Header{"Ajp_eppn": {"someone@johnshopkins.edu"}},
+		admin:    &web.Credentials{Username: "admin", Password: "secret"},
+		username: "admin",
+		password: "secret",
+		status:   http.StatusOK,
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			service.Admin = c.admin
+
+			body, _ := json.Marshal(web.BatchRequest{Submissions: []string{submissionURI}, Headers: c.headers})
+			req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+			if c.username != "" {
+				req.SetBasicAuth(c.username, c.password)
+			}
+
+			resp := httptest.NewRecorder()
+			service.RequestBatch(resp, req)
+
+			if resp.Code != c.status {
+				t.Fatalf("Expected status %d, got %d: %s", c.status, resp.Code, resp.Body.String())
+			}
+		})
+	}
+}
+
+// failingWriter is a response writer that can't write
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingWriter) Write([]byte) (int, error) {
+	return 0, fmt.Errorf("connection reset")
+}
+
+// No more submissions are evaluated once the client goes away, or results can't be written
+func TestBatchCancelled(t *testing.T) {
+	const count = 100
+
+	content := testFetcher{}
+	for uri, entity := range fedora {
+		content[uri] = entity
+	}
+
+	var submissions []string
+	for i := 0; i < count; i++ {
+		uri := fmt.Sprintf("/submissions/batch%d", i)
+		content[privateBaseURI+uri] = fedora[privateBaseURI+"/submissions/1"]
+		submissions = append(submissions, publicBaseURI+uri)
+	}
+
+	body, _ := json.Marshal(web.BatchRequest{Submissions: submissions})
+
+	cases := []struct {
+		name    string
+		context func() context.Context
+		writer  func() http.ResponseWriter
+	}{{
+		name: "client gone",
+		context: func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		},
+		writer: func() http.ResponseWriter { return httptest.NewRecorder() },
+	}, {
+		name:    "write fails",
+		context: context.Background,
+		writer:  func() http.ResponseWriter { return failingWriter{httptest.NewRecorder()} },
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			fetcher := &countingFetcher{testFetcher: content, fetches: map[string]int{}}
+			service := repositoriesService(t, content)
+			service.Fetcher = fetcher
+			service.BatchWorkers = 1
+
+			req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+
+			service.RequestBatch(c.writer(), req.WithContext(c.context()))
+
+			fetcher.mutex.Lock()
+			defer fetcher.mutex.Unlock()
+
+			evaluated := 0
+			for _, uri := range submissions {
+				evaluated += fetcher.fetches[strings.Replace(uri, publicBaseURI, privateBaseURI, 1)]
+			}
+
+			if evaluated >= count {
+				t.Fatalf("Evaluated all %d submissions", evaluated)
+			}
+		})
+	}
+}
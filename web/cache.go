@@ -0,0 +1,69 @@
+package web
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+// cachingFetcher is a PassEntityFetcher that fetches each entity at most once, sharing its
+// content (or the error fetching it) among all who ask for it, concurrently or later.  Public
+// and private URIs of the same entity share a cache entry.  It is meant to live only as long
+// as a request, so entities are never stale for long.
+type cachingFetcher struct {
+	fetcher rule.PassEntityFetcher
+	replace BaseURIReplacer
+	metrics *Metrics
+	mutex   sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	once    sync.Once
+	content json.RawMessage
+	err     error
+}
+
+func newCachingFetcher(fetcher rule.PassEntityFetcher, replace BaseURIReplacer, metrics *Metrics) *cachingFetcher {
+	return &cachingFetcher{
+		fetcher: fetcher,
+		replace: replace,
+		metrics: metrics,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// FetchEntity un-marshals the content of the entity at the given URL into the provided struct or
+// map, fetching it only if it hasn't been fetched already
+func (c *cachingFetcher) FetchEntity(url string, entityPointer interface{}) error {
+	entry := c.entry(url)
+
+	entry.once.Do(func() {
+		entry.err = c.fetcher.FetchEntity(url, &entry.content)
+	})
+	if entry.err != nil {
+		return entry.err
+	}
+
+	return json.Unmarshal(entry.content, entityPointer)
+}
+
+func (c *cachingFetcher) entry(url string) *cacheEntry {
+	key := url
+	if c.replace != nil {
+		key, _ = c.replace.PublicWithPrivate(url)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	c.metrics.observeCacheLookup(ok)
+
+	return entry
+}
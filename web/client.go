@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 )
@@ -26,6 +27,8 @@ type InternalPassClient struct {
 	ExternalBaseURI string
 	InternalBaseURI string
 	Credentials     *Credentials
+	Metrics         *Metrics       // records fetches, if not nil
+	upstream        upstreamStatus // outcome of recent requests to the repository
 }
 
 type Credentials struct {
@@ -40,15 +43,53 @@ type Requester interface {
 
 // FetchEntity fetches and parses the PASS entity at the given URL to the struct or map
 // pointed to by entityPointer
-func (c *InternalPassClient) FetchEntity(url string, entityPointer interface{}) error {
-	url, err := c.translate(url)
+func (c *InternalPassClient) FetchEntity(url string, entityPointer interface{}) (err error) {
+	defer func(start time.Time) {
+		c.Metrics.observeFetch(time.Since(start), err)
+	}(time.Now())
+
+	url, err = c.translate(url)
 	if err != nil {
 		return errors.Wrapf(err, "error translating url")
 	}
 
+	resp, err := c.get(url)
+	c.upstream.record(url, resp, err)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = json.NewDecoder(resp.Body).Decode(entityPointer)
+	if err != nil {
+		return errors.Wrapf(err, "could not decode resource JSON")
+	}
+
+	return nil
+}
+
+// Ping verifies that the repository is reachable with the client's credentials, and that
+// fetching entities from it hasn't been failing recently.  A successful ping does not excuse
+// recent failures, since the base URI may respond when entities don't.
+func (c *InternalPassClient) Ping() error {
+	resp, err := c.get(c.InternalBaseURI)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return errors.Errorf("%s responded with status %d", c.InternalBaseURI, resp.StatusCode)
+	}
+
+	return c.upstream.check()
+}
+
+// get performs a GET request to the given private URL
+func (c *InternalPassClient) get(url string) (*http.Response, error) {
 	request, err := http.NewRequest(http.MethodGet, url, nil)
 	if err != nil {
-		return errors.Wrapf(err, "could not build http request to %s", url)
+		return nil, errors.Wrapf(err, "could not build http request to %s", url)
 	}
 
 	if c.Credentials != nil {
@@ -59,16 +100,10 @@ func (c *InternalPassClient) FetchEntity(url string, entityPointer interface{})
 
 	resp, err := c.Do(request)
 	if err != nil {
-		return errors.Wrapf(err, "error connecting to %s", url)
+		return nil, errors.Wrapf(err, "error connecting to %s", url)
 	}
-	defer resp.Body.Close()
 
-	err = json.NewDecoder(resp.Body).Decode(entityPointer)
-	if err != nil {
-		return errors.Wrapf(err, "could not decode resource JSON")
-	}
-
-	return nil
+	return resp, nil
 }
 
 func (c *InternalPassClient) translate(uri string) (string, error) {
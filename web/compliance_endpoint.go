@@ -0,0 +1,88 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+const (
+	repositoryQueryParam = "repository"
+)
+
+type complianceRequest struct {
+	*PolicyService
+	req  *http.Request
+	resp http.ResponseWriter
+}
+
+func (co *complianceRequest) handleGet() {
+	co.performRequest(co.req.URL.Query())
+}
+
+func (co *complianceRequest) handlePost() {
+	if co.req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		http.Error(co.resp,
+			"expected media type application/x-www-form-urlencoded, instead got "+
+				co.req.Header.Get("Content-Type"),
+			http.StatusBadRequest)
+		return
+	}
+
+	if err := co.req.ParseForm(); err != nil {
+		http.Error(co.resp, "Could not parse form input: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	co.performRequest(co.req.PostForm)
+}
+
+// performRequest checks the chosen repositories against the requirements of the submission.
+// Both are given as public URIs.
+func (co *complianceRequest) performRequest(params url.Values) {
+	publicSubmissionURI := params.Get(submissionQueryParam)
+	if publicSubmissionURI == "" {
+		http.Error(co.resp, "No submission value provided", http.StatusBadRequest)
+		return
+	}
+
+	privateSubmissionURI, ok := co.Replace.PublicWithPrivate(publicSubmissionURI)
+	if !ok {
+		http.Error(co.resp, fmt.Sprintf("submission URI %s does not have the expected PASS baseURI", publicSubmissionURI),
+			http.StatusInternalServerError)
+		return
+	}
+
+	sub := submission{uri: privateSubmissionURI}
+	analyzed, err := co.requirements(sub, co.context(sub, co.req.Header), reconcileOptionsFrom(params))
+	if err != nil {
+		log.Printf("Error analyzing requirements: %+v", err)
+		http.Error(co.resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var chosen []rule.Repository
+	for _, uri := range params[repositoryQueryParam] {
+		publicURI, _ := co.Replace.PrivateWithPublic(uri)
+		chosen = append(chosen, rule.Repository{ID: publicURI})
+	}
+
+	compliance := analyzed.requirements.
+		TranslateURIs(co.Replace.PrivateWithPublic).
+		Check(chosen)
+
+	warn(co.resp, analyzed.warnings)
+
+	encoder := json.NewEncoder(co.resp)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(compliance)
+	if err != nil {
+		log.Printf("error encoding JSON response: %s", err)
+		http.Error(co.resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
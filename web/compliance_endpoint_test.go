@@ -0,0 +1,103 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/oa-pass/pass-policy-service/web"
+)
+
+func TestComplianceEndpoint(t *testing.T) {
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-rules": [
+			{
+				"rule-id": "funders",
+				"policy-id": "${submission.grants.primaryFunder.policy}",
+				"type": "funder",
+				"repositories": [{"repository-id": "${policy.repositories}"}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("rules failed validation %+v", err)
+	}
+
+	service := web.PolicyService{
+		Rules:   rules,
+		Fetcher: testFetcher(fedora),
+		Replace: baseURIs,
+	}
+
+	pmc := publicBaseURI + "/repositories/pmc"
+	other := publicBaseURI + "/repositories/other"
+	nih := rule.Source{PolicyID: publicBaseURI + "/policies/nih", RuleID: "funders"}
+
+	cases := []struct {
+		name     string
+		chosen   []string
+		expected rule.Compliance
+	}{{
+		name:   "compliant",
+		chosen: []string{pmc},
+		expected: rule.Compliance{
+			Compliant:       true,
+			Satisfied:       []rule.Source{nih},
+			Unsatisfied:     []rule.Source{},
+			MissingRequired: []rule.Repository{},
+			UnmetOneOf:      [][]rule.Repository{},
+			Unnecessary:     []rule.Repository{},
+		},
+	}, {
+		name:   "noncompliant",
+		chosen: []string{other},
+		expected: rule.Compliance{
+			Satisfied:       []rule.Source{},
+			Unsatisfied:     []rule.Source{nih},
+			MissingRequired: []rule.Repository{{ID: pmc, Sources: []rule.Source{nih}}},
+			UnmetOneOf:      [][]rule.Repository{},
+			Unnecessary:     []rule.Repository{{ID: other}},
+		},
+	}}
+
+	for _, c := range cases {
+		c := c
+		params := url.Values{
+			"submission": {submissionURI},
+			"repository": c.chosen,
+		}
+
+		for _, req := range []*http.Request{
+			httptest.NewRequest(http.MethodGet, "/compliance?"+params.Encode(), nil),
+			func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/compliance", strings.NewReader(params.Encode()))
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				return req
+			}(),
+		} {
+			req := req
+			t.Run(c.name+"_"+req.Method, func(t *testing.T) {
+				resp := httptest.NewRecorder()
+				service.RequestCompliance(resp, req)
+
+				if resp.Code != http.StatusOK {
+					t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+				}
+
+				var compliance rule.Compliance
+				_ = json.Unmarshal(resp.Body.Bytes(), &compliance)
+
+				diffs := deep.Equal(compliance, c.expected)
+				if len(diffs) > 0 {
+					t.Fatalf("Did not get expected compliance: %s", strings.Join(diffs, "\n"))
+				}
+			})
+		}
+	}
+}
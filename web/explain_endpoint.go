@@ -0,0 +1,201 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+)
+
+const (
+	headerQueryParam    = "header"
+	headerAuthorization = "Authorization"
+)
+
+// Explanation is the response of the explain endpoint:  how the repositories endpoint arrives at
+// the repository requirements of a submission.  Variables and rules are as evaluated, so contain
+// private or relative URIs.  Everything else contains public URIs.
+type Explanation struct {
+	Submission        string               `json:"submission"`
+	Headers           http.Header          `json:"headers"`            // headers the rules were evaluated with
+	Variables         []rule.VariableTrace `json:"variables"`          // values of the variables the rules used
+	Rules             []rule.RuleTrace     `json:"rules"`              // evaluation of each rule, for each policy
+	Policies          []PolicyResult       `json:"policies"`           // policies that apply to the submission
+	EffectivePolicies []PolicyResult       `json:"effective-policies"` // applicable policies that are effective
+	Warnings          []string             `json:"warnings,omitempty"` // problems ignored in lenient mode
+	Analysis          *rule.Requirements   `json:"analysis,omitempty"` // requirements of all applicable policies
+	Requirements      *rule.Requirements   `json:"requirements,omitempty"`
+	Elided            []rule.Elision       `json:"elided,omitempty"` // repositories elided from the analysis
+	Error             string               `json:"error,omitempty"`
+}
+
+type explainRequest struct {
+	*PolicyService
+	req  *http.Request
+	resp http.ResponseWriter
+}
+
+// RequestExplain explains the repository requirements of a submission, for those with
+// administrative credentials
+func (s *PolicyService) RequestExplain(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r, "explain endpoint") {
+		return
+	}
+
+	s = s.current()
+	s.doRequest(&explainRequest{s, r, w}, w, r)
+}
+
+// authorizeAdmin determines if a request is authenticated with the administrative credentials,
+// as the given feature requires.  If not, it responds that the feature is not enabled if there
+// are no administrative credentials, or that the request is unauthorized.
+func (s *PolicyService) authorizeAdmin(w http.ResponseWriter, r *http.Request, feature string) bool {
+	if s.Admin == nil {
+		http.Error(w, feature+" is not enabled", http.StatusForbidden)
+		return false
+	}
+
+	if !s.Admin.match(r) {
+		w.Header().Set("WWW-Authenticate", `Basic realm="pass-policy-service"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// match determines if a request is authenticated with the credentials
+func (c *Credentials) match(r *http.Request) bool {
+	username, password, ok := r.BasicAuth()
+	return ok &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(c.Username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(c.Password)) == 1
+}
+
+func (e *explainRequest) handleGet() {
+	e.performRequest(e.req.URL.Query())
+}
+
+func (e *explainRequest) handlePost() {
+	if isJSON(e.req) {
+		sub, err := e.inlineSubmission(e.req)
+		if err != nil {
+			http.Error(e.resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		e.explain(sub, e.req.URL.Query())
+		return
+	}
+
+	if e.req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		http.Error(e.resp,
+			"expected media type application/x-www-form-urlencoded or application/json, instead got "+
+				e.req.Header.Get("Content-Type"),
+			http.StatusBadRequest)
+		return
+	}
+
+	if err := e.req.ParseForm(); err != nil {
+		http.Error(e.resp, "Could not parse form input: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	e.performRequest(e.req.PostForm)
+}
+
+func (e *explainRequest) performRequest(params url.Values) {
+	publicSubmissionURI := params.Get(submissionQueryParam)
+	if publicSubmissionURI == "" {
+		http.Error(e.resp, "No submission value provided", http.StatusBadRequest)
+		return
+	}
+
+	privateSubmissionURI, ok := e.Replace.PublicWithPrivate(publicSubmissionURI)
+	if !ok {
+		http.Error(e.resp, fmt.Sprintf("submission URI %s does not have the expected PASS baseURI", privateSubmissionURI),
+			http.StatusInternalServerError)
+		return
+	}
+
+	e.explain(submission{uri: privateSubmissionURI}, params)
+}
+
+// explain runs the analysis of the repositories endpoint, tracing the evaluation of the rules.
+// The explanation is sent even if the analysis fails, as it shows how far it got.
+func (e *explainRequest) explain(sub submission, params url.Values) {
+	headers, err := explainHeaders(e.req.Header, params[headerQueryParam])
+	if err != nil {
+		http.Error(e.resp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	context := e.context(sub, headers)
+	context.Trace = &rule.Trace{Variables: []rule.VariableTrace{}, Rules: []rule.RuleTrace{}}
+
+	analyzed, err := e.requirements(sub, context, reconcileOptionsFrom(params))
+
+	explanation := Explanation{
+		Submission:        sub.String(),
+		Headers:           headers,
+		Variables:         context.Trace.Variables,
+		Rules:             context.Trace.Rules,
+		Policies:          e.policyResults(analyzed.policies),
+		EffectivePolicies: e.policyResults(analyzed.effective),
+		Warnings:          analyzed.warnings,
+	}
+	if sub.uri != "" {
+		explanation.Submission, _ = e.Replace.PrivateWithPublic(sub.uri)
+	}
+	if analyzed.analyzed != nil {
+		explanation.Analysis = analyzed.analyzed.TranslateURIs(e.Replace.PrivateWithPublic)
+	}
+	if analyzed.requirements != nil {
+		explanation.Requirements = analyzed.requirements.Recommend().TranslateURIs(e.Replace.PrivateWithPublic)
+		explanation.Elided = analyzed.report.TranslateURIs(e.Replace.PrivateWithPublic).Elided
+	}
+	if err != nil {
+		log.Printf("Error analyzing requirements: %+v", err)
+		explanation.Error = err.Error()
+	}
+
+	encoder := json.NewEncoder(e.resp)
+	encoder.SetIndent("", "  ")
+	err = encoder.Encode(explanation)
+	if err != nil {
+		log.Printf("error encoding JSON response: %s", err)
+		http.Error(e.resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// explainHeaders determines the headers to evaluate the rules with:  those given as parameters
+// of the form "Name: value", if any, so as to explain the results for somebody else.  Otherwise,
+// the headers of the request itself, less its administrative credentials.
+func explainHeaders(requestHeaders http.Header, params []string) (http.Header, error) {
+	headers := make(http.Header)
+
+	if len(params) == 0 {
+		for name, values := range requestHeaders {
+			if name != headerAuthorization {
+				headers[name] = values
+			}
+		}
+		return headers, nil
+	}
+
+	for _, param := range params {
+		parts := strings.SplitN(param, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("header %q is not of the form 'Name: value'", param)
+		}
+		headers.Add(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+	}
+
+	return headers, nil
+}
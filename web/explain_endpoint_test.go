@@ -0,0 +1,160 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/web"
+)
+
+func TestExplainAuthentication(t *testing.T) {
+	service := repositoriesService(t, testFetcher(fedora))
+
+	cases := []struct {
+		name     string
+		admin    *web.Credentials
+		username string
+		password string
+		status   int
+	}{{
+		name:   "disabled",
+		status: http.StatusForbidden,
+	}, {
+		name:   "no credentials",
+		admin:  &web.Credentials{Username: "admin", Password: "secret"},
+		status: http.StatusUnauthorized,
+	}, {
+		name:     "wrong credentials",
+		admin:    &web.Credentials{Username: "admin", Password: "secret"},
+		username: "admin",
+		password: "guess",
+		status:   http.StatusUnauthorized,
+	}, {
+		name:     "authenticated",
+		admin:    &web.Credentials{Username: "admin", Password: "secret"},
+		username: "admin",
+		password: "secret",
+		status:   http.StatusOK,
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			service.Admin = c.admin
+
+			req := httptest.NewRequest(http.MethodGet, "/explain?submission="+url.QueryEscape(submissionURI), nil)
+			if c.username != "" {
+				req.SetBasicAuth(c.username, c.password)
+			}
+
+			resp := httptest.NewRecorder()
+			service.RequestExplain(resp, req)
+
+			if resp.Code != c.status {
+				t.Fatalf("Expected status %d, got %d: %s", c.status, resp.Code, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestExplainEndpoint(t *testing.T) {
+	service := repositoriesService(t, testFetcher(fedora))
+	service.Admin = &web.Credentials{Username: "admin", Password: "secret"}
+
+	params := url.Values{
+		"submission": {submissionURI},
+		"header":     {"Ajp_eppn: someone@johnshopkins.edu"},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/explain?"+params.Encode(), nil)
+	req.SetBasicAuth("admin", "secret")
+
+	resp := httptest.NewRecorder()
+	service.RequestExplain(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var explanation web.Explanation
+	_ = json.Unmarshal(resp.Body.Bytes(), &explanation)
+
+	if explanation.Error != "" {
+		t.Fatalf("Got error %s", explanation.Error)
+	}
+
+	if diffs := deep.Equal(explanation.Headers, http.Header{"Ajp_eppn": {"someone@johnshopkins.edu"}}); len(diffs) > 0 {
+		t.Fatalf("Did not evaluate with the given headers: %s", strings.Join(diffs, "\n"))
+	}
+
+	var rules []string
+	for _, r := range explanation.Rules {
+		if r.Applies {
+			rules = append(rules, r.RuleID+" "+r.PolicyID)
+		}
+	}
+
+	if diffs := deep.Equal(rules, []string{
+		"funders " + privateBaseURI + "/policies/nih",
+		"#/policy-rules/1 /policies/institution",
+	}); len(diffs) > 0 {
+		t.Fatalf("Did not get expected rule traces: %s", strings.Join(diffs, "\n"))
+	}
+
+	ids := func(policies []web.PolicyResult) []string {
+		var ids []string
+		for _, p := range policies {
+			ids = append(ids, strings.TrimPrefix(p.ID, publicBaseURI))
+		}
+		return ids
+	}
+
+	if diffs := deep.Equal(ids(explanation.Policies), []string{"/policies/nih", "/policies/institution"}); len(diffs) > 0 {
+		t.Fatalf("Did not get expected policies: %s", strings.Join(diffs, "\n"))
+	}
+
+	if diffs := deep.Equal(ids(explanation.EffectivePolicies), []string{"/policies/nih"}); len(diffs) > 0 {
+		t.Fatalf("Did not get expected effective policies: %s", strings.Join(diffs, "\n"))
+	}
+
+	if len(explanation.Analysis.Required) != 2 || len(explanation.Requirements.Required) != 1 {
+		t.Fatalf("Expected the institutional repository to be analyzed, then elided: %+v", explanation)
+	}
+
+	if len(explanation.Elided) != 1 || explanation.Elided[0].ID != publicBaseURI+"/repositories/ir" {
+		t.Fatalf("Expected the institutional repository to be elided: %+v", explanation.Elided)
+	}
+}
+
+func TestExplainFailure(t *testing.T) {
+	service := repositoriesService(t, testFetcher(fedora))
+	service.Admin = &web.Credentials{Username: "admin", Password: "secret"}
+
+	// The submission isn't in Fedora
+	req := httptest.NewRequest(http.MethodGet,
+		"/explain?submission="+url.QueryEscape(publicBaseURI+"/submissions/missing"), nil)
+	req.SetBasicAuth("admin", "secret")
+
+	resp := httptest.NewRecorder()
+	service.RequestExplain(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var explanation web.Explanation
+	_ = json.Unmarshal(resp.Body.Bytes(), &explanation)
+
+	if explanation.Error == "" || explanation.Requirements != nil {
+		t.Fatalf("Expected an explanation of the failure, got %+v", explanation)
+	}
+
+	if _, ok := explanation.Headers["Authorization"]; ok {
+		t.Fatalf("Administrative credentials should not be evaluated: %+v", explanation.Headers)
+	}
+}
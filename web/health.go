@@ -0,0 +1,121 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	upstreamFailureThreshold = 3           // consecutive failures after which the repository is presumed to be failing
+	upstreamFailureWindow    = time.Minute // how long failures count against readiness
+)
+
+// Pinger verifies that an upstream service (e.g. the PASS repository) is available
+type Pinger interface {
+	Ping() error
+}
+
+// upstreamStatus records the outcome of recent requests to an upstream service.  A request
+// fails if there is no response, or the response is a server error.
+type upstreamStatus struct {
+	mutex       sync.Mutex
+	failures    int // consecutive failures
+	lastFailure time.Time
+	lastError   error
+}
+
+// record the outcome of a request to the given URL
+func (u *upstreamStatus) record(url string, resp *http.Response, err error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	switch {
+	case err != nil:
+	case resp.StatusCode >= http.StatusInternalServerError:
+		err = errors.Errorf("%s responded with status %d", url, resp.StatusCode)
+	default:
+		u.failures = 0
+		return
+	}
+
+	u.failures++
+	u.lastFailure = time.Now()
+	u.lastError = err
+}
+
+// check returns an error if requests have been failing recently
+func (u *upstreamStatus) check() error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.failures >= upstreamFailureThreshold && time.Since(u.lastFailure) < upstreamFailureWindow {
+		return errors.Wrapf(u.lastError, "%d consecutive failed requests, most recently at %s",
+			u.failures, u.lastFailure.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// Readiness is the response of the readiness endpoint:  whether the service is ready to handle
+// requests, and the result of each check that determines it
+type Readiness struct {
+	Ready        bool             `json:"ready"`
+	RulesVersion string           `json:"rules-version,omitempty"` // version of the rules from the rules file, if any
+	Checks       []ReadinessCheck `json:"checks"`
+}
+
+// ReadinessCheck is the result of a readiness check
+type ReadinessCheck struct {
+	Name  string `json:"name"`
+	Ready bool   `json:"ready"`
+	Error string `json:"error,omitempty"`
+}
+
+// RequestHealth responds if the service is alive
+func (s *PolicyService) RequestHealth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// RequestReadiness determines if the service is ready to handle requests:  its rules are loaded,
+// and the PASS repository is available, if the fetcher can tell
+func (s *PolicyService) RequestReadiness(w http.ResponseWriter, r *http.Request) {
+	rules, version := s.rules()
+	readiness := Readiness{Ready: true, RulesVersion: version}
+
+	check := func(name string, err error) {
+		result := ReadinessCheck{Name: name, Ready: err == nil}
+		if err != nil {
+			log.Printf("Not ready: %s: %+v", name, err)
+			result.Error = err.Error()
+			readiness.Ready = false
+		}
+		readiness.Checks = append(readiness.Checks, result)
+	}
+
+	if rules == nil {
+		check("rules", errors.New("no rules are loaded"))
+	} else {
+		check("rules", nil)
+	}
+
+	if pinger, ok := s.Fetcher.(Pinger); ok {
+		check("fedora", pinger.Ping())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(readiness); err != nil {
+		log.Printf("error encoding JSON response: %s", err)
+	}
+}
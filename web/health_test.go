@@ -0,0 +1,172 @@
+package web_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/oa-pass/pass-policy-service/web"
+)
+
+func TestHealth(t *testing.T) {
+	service := web.PolicyService{}
+
+	resp := httptest.NewRecorder()
+	service.RequestHealth(resp, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Got status %d", resp.Code)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+
+	// Fedora responds to the base URI with the given status, and fails to respond to anything else
+	fedora := func(status int) web.Requester {
+		return &fakeRequester{
+			f: func(req *http.Request) (*http.Response, error) {
+				if req.URL.String() != privateBaseURI {
+					return nil, fmt.Errorf("connection refused")
+				}
+				return &http.Response{StatusCode: status, Body: &fakeBody{Reader: strings.NewReader("{}")}}, nil
+			},
+		}
+	}
+
+	cases := []struct {
+		name     string
+		rules    rule.PolicyResolver
+		fedora   web.Requester
+		failures int
+		status   int
+		checks   []web.ReadinessCheck
+	}{{
+		name:   "ready",
+		rules:  &rule.DSL{},
+		fedora: fedora(http.StatusOK),
+		status: http.StatusOK,
+		checks: []web.ReadinessCheck{{Name: "rules", Ready: true}, {Name: "fedora", Ready: true}},
+	}, {
+		name:   "no rules",
+		fedora: fedora(http.StatusOK),
+		status: http.StatusServiceUnavailable,
+		checks: []web.ReadinessCheck{
+			{Name: "rules", Ready: false, Error: "no rules are loaded"},
+			{Name: "fedora", Ready: true},
+		},
+	}, {
+		name:   "bad credentials",
+		rules:  &rule.DSL{},
+		fedora: fedora(http.StatusUnauthorized),
+		status: http.StatusServiceUnavailable,
+		checks: []web.ReadinessCheck{
+			{Name: "rules", Ready: true},
+			{Name: "fedora", Ready: false, Error: privateBaseURI + " responded with status 401"},
+		},
+	}, {
+		name:     "few recent failures",
+		rules:    &rule.DSL{},
+		fedora:   fedora(http.StatusOK),
+		failures: 2,
+		status:   http.StatusOK,
+		checks:   []web.ReadinessCheck{{Name: "rules", Ready: true}, {Name: "fedora", Ready: true}},
+	}, {
+		name:     "recent failures",
+		rules:    &rule.DSL{},
+		fedora:   fedora(http.StatusOK),
+		failures: 3,
+		status:   http.StatusServiceUnavailable,
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			client := &web.InternalPassClient{
+				Requester:       c.fedora,
+				ExternalBaseURI: publicBaseURI,
+				InternalBaseURI: privateBaseURI,
+			}
+
+			for i := 0; i < c.failures; i++ {
+				var entity map[string]interface{}
+				_ = client.FetchEntity(privateBaseURI+"/submissions/1", &entity)
+			}
+
+			service := web.PolicyService{Rules: c.rules, Fetcher: client}
+
+			resp := httptest.NewRecorder()
+			service.RequestReadiness(resp, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if resp.Code != c.status {
+				t.Fatalf("Expected status %d, got %d: %s", c.status, resp.Code, resp.Body.String())
+			}
+
+			var readiness web.Readiness
+			_ = json.Unmarshal(resp.Body.Bytes(), &readiness)
+
+			if readiness.Ready != (c.status == http.StatusOK) {
+				t.Fatalf("Readiness does not agree with status: %s", resp.Body.String())
+			}
+
+			if c.checks == nil {
+				return
+			}
+
+			if diffs := deep.Equal(readiness.Checks, c.checks); len(diffs) > 0 {
+				t.Fatalf("Did not get expected checks: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
+// Readiness checks the rules of the rules file, which are what requests use
+func TestReadinessRulesFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.json")
+	writeRules(t, path, rulesDoc("/policies/a"))
+
+	service, err := web.NewPolicyServiceFromFile(path, testFetcher(fedora))
+	if err != nil {
+		t.Fatalf("could not create service: %+v", err)
+	}
+
+	readiness := func() (int, web.Readiness) {
+		resp := httptest.NewRecorder()
+		service.RequestReadiness(resp, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+		var readiness web.Readiness
+		_ = json.Unmarshal(resp.Body.Bytes(), &readiness)
+		return resp.Code, readiness
+	}
+
+	if status, ready := readiness(); status != http.StatusOK || ready.RulesVersion == "" {
+		t.Fatalf("Expected to be ready with a rules version, got status %d: %+v", status, ready)
+	}
+
+	// A rules file that hasn't been loaded has no rules
+	service.RulesFile = &web.RulesFile{Path: path}
+
+	status, ready := readiness()
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected not to be ready, got status %d: %+v", status, ready)
+	}
+
+	if diffs := deep.Equal(ready.Checks, []web.ReadinessCheck{
+		{Name: "rules", Ready: false, Error: "no rules are loaded"},
+	}); len(diffs) > 0 {
+		t.Fatalf("Did not get expected checks: %s", strings.Join(diffs, "\n"))
+	}
+}
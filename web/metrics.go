@@ -0,0 +1,182 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics collects measurements of the service:  requests to its endpoints, fetches from the
+// PASS repository, entity cache lookups, policy rule matches, and reloads of the rules.  They
+// are exposed in the Prometheus text format.  A nil *Metrics measures nothing.
+type Metrics struct {
+	registry        *prometheus.Registry
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	fetches         prometheus.Counter
+	fetchErrors     prometheus.Counter
+	fetchDuration   prometheus.Histogram
+	cacheLookups    *prometheus.CounterVec
+	ruleMatches     *prometheus.CounterVec
+	reloads         *prometheus.CounterVec
+	rulesInfo       *prometheus.GaugeVec
+}
+
+// NewMetrics creates an empty collection of metrics
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "policy_service_requests_total",
+			Help: "Requests handled, by endpoint, method, and status code.",
+		}, []string{"endpoint", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name: "policy_service_request_duration_seconds",
+			Help: "Time taken to handle requests, by endpoint.",
+		}, []string{"endpoint"}),
+		fetches: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "policy_service_fedora_fetches_total",
+			Help: "Entities fetched from the PASS repository.",
+		}),
+		fetchErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "policy_service_fedora_fetch_errors_total",
+			Help: "Entities that could not be fetched from the PASS repository.",
+		}),
+		fetchDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "policy_service_fedora_fetch_duration_seconds",
+			Help: "Time taken to fetch entities from the PASS repository.",
+		}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "policy_service_cache_lookups_total",
+			Help: "Lookups of entities in the cache shared by a batch, by result (hit or miss).",
+		}, []string{"result"}),
+		ruleMatches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "policy_service_rule_matches_total",
+			Help: "Policies produced by each policy rule.",
+		}, []string{"rule_id"}),
+		reloads: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "policy_service_rules_reloads_total",
+			Help: "Attempts to load the rules file, by result (success or failure).",
+		}, []string{"result"}),
+		rulesInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "policy_service_rules_info",
+			Help: "The version of the active rules.",
+		}, []string{"version"}),
+	}
+
+	m.registry.MustRegister(m.requests, m.requestDuration, m.fetches, m.fetchErrors, m.fetchDuration,
+		m.cacheLookups, m.ruleMatches, m.reloads, m.rulesInfo)
+
+	return m
+}
+
+// Instrument wraps an endpoint's handler, recording each request it handles
+func (m *Metrics) Instrument(endpoint string, handler http.HandlerFunc) http.HandlerFunc {
+	if m == nil {
+		return handler
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		handler(recorder, r)
+
+		m.requests.WithLabelValues(endpoint, r.Method, strconv.Itoa(recorder.status)).Inc()
+		m.requestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}
+}
+
+// SetRulesVersion records the version of the active rules
+func (m *Metrics) SetRulesVersion(version string) {
+	if m == nil {
+		return
+	}
+
+	m.rulesInfo.Reset()
+	m.rulesInfo.WithLabelValues(version).Set(1)
+}
+
+// observeFetch records a fetch from the PASS repository
+func (m *Metrics) observeFetch(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.fetches.Inc()
+	if err != nil {
+		m.fetchErrors.Inc()
+	}
+	m.fetchDuration.Observe(duration.Seconds())
+}
+
+// observeCacheLookup records a lookup in an entity cache
+func (m *Metrics) observeCacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+
+	if hit {
+		m.cacheLookups.WithLabelValues("hit").Inc()
+	} else {
+		m.cacheLookups.WithLabelValues("miss").Inc()
+	}
+}
+
+// observeMatch records a policy produced by a rule
+func (m *Metrics) observeMatch(policy rule.Policy) {
+	if m == nil {
+		return
+	}
+
+	m.ruleMatches.WithLabelValues(policy.RuleID).Inc()
+}
+
+// observeReload records an attempt to load the rules file
+func (m *Metrics) observeReload(success bool) {
+	if m == nil {
+		return
+	}
+
+	if success {
+		m.reloads.WithLabelValues("success").Inc()
+	} else {
+		m.reloads.WithLabelValues("failure").Inc()
+	}
+}
+
+// RequestMetrics exposes the metrics of the service in the Prometheus text format
+func (s *PolicyService) RequestMetrics(w http.ResponseWriter, r *http.Request) {
+	if s.Metrics == nil {
+		http.Error(w, "metrics are not enabled", http.StatusNotFound)
+		return
+	}
+
+	promhttp.HandlerFor(s.Metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// statusRecorder remembers the status code of a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush flushes the response, if it can be, so that streamed responses still stream
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
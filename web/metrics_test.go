@@ -0,0 +1,91 @@
+package web_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oa-pass/pass-policy-service/web"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := web.NewMetrics()
+
+	service := repositoriesService(t, testFetcher(fedora))
+	service.Metrics = metrics
+
+	policies := metrics.Instrument("policies", service.RequestPolicies)
+	policies(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet,
+		"/policies?submission="+url.QueryEscape(submissionURI), nil))
+	policies(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/policies", nil))
+
+	// Two submissions in a batch share the same funder
+	batch := metrics.Instrument("batch", service.RequestBatch)
+	req := httptest.NewRequest(http.MethodPost, "/batch",
+		strings.NewReader(`{"submissions": ["`+submissionURI+`", "`+submissionURI+`"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	batch(httptest.NewRecorder(), req)
+
+	client := &web.InternalPassClient{
+		Requester: &fakeRequester{
+			f: func(req *http.Request) (*http.Response, error) {
+				return nil, fmt.Errorf("connection refused")
+			},
+		},
+		ExternalBaseURI: publicBaseURI,
+		InternalBaseURI: privateBaseURI,
+		Metrics:         metrics,
+	}
+	var entity map[string]interface{}
+	_ = client.FetchEntity(submissionURI, &entity)
+
+	repositories := metrics.Instrument("repositories", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+	})
+	repositories(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/repositories", nil))
+
+	resp := httptest.NewRecorder()
+	service.RequestMetrics(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.HasPrefix(resp.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Fatalf("Got content type %s", resp.Header().Get("Content-Type"))
+	}
+
+	exposition := resp.Body.String()
+	for _, expected := range []string{
+		"# TYPE policy_service_requests_total counter",
+		`policy_service_requests_total{code="200",endpoint="policies",method="GET"} 1`,
+		`policy_service_requests_total{code="400",endpoint="policies",method="GET"} 1`,
+		`policy_service_requests_total{code="200",endpoint="batch",method="POST"} 1`,
+		"# TYPE policy_service_request_duration_seconds histogram",
+		`policy_service_request_duration_seconds_bucket{endpoint="repositories",le="0.025"} 0`,
+		`policy_service_request_duration_seconds_bucket{endpoint="repositories",le="+Inf"} 1`,
+		`policy_service_request_duration_seconds_count{endpoint="repositories"} 1`,
+		"policy_service_fedora_fetches_total 1",
+		"policy_service_fedora_fetch_errors_total 1",
+		"policy_service_fedora_fetch_duration_seconds_count 1",
+		`policy_service_cache_lookups_total{result="hit"} 4`,
+		`policy_service_cache_lookups_total{result="miss"} 4`,
+		`policy_service_rule_matches_total{rule_id="funders"} 3`,
+		`policy_service_rule_matches_total{rule_id="#/policy-rules/1"} 3`,
+	} {
+		if !strings.Contains(exposition, expected+"\n") {
+			t.Errorf("Expected %s in\n%s", expected, exposition)
+		}
+	}
+}
+
+func TestMetricsDisabled(t *testing.T) {
+	service := web.PolicyService{}
+
+	resp := httptest.NewRecorder()
+	service.RequestMetrics(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if resp.Code != http.StatusNotFound {
+		t.Fatalf("Expected not found, got %d", resp.Code)
+	}
+}
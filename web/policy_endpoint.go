@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 
 	"github.com/oa-pass/pass-policy-service/rule"
 )
@@ -19,21 +20,19 @@ type policyRequest struct {
 	resp http.ResponseWriter
 }
 
-// PolicyResult is an item returned in a policy service response, indicating a policy ID, and
-// an originating type (funder, institution)
+// PolicyResult is an item returned in a policy service response, indicating a policy ID,
+// an originating type (e.g. funder, institution) from the rules' vocabulary of policy types,
+// the ID of the rule that matched, and a human-readable explanation of why the policy applies,
+// if the rules provide one
 type PolicyResult struct {
-	ID   string `json:"id"`
-	Type string `json:"type"`
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	RuleID  string `json:"rule-id,omitempty"`
+	Message string `json:"message,omitempty"`
 }
 
-func (p *policyRequest) findPolicies(submission string, headers map[string][]string) ([]rule.Policy, error) {
-	context := &rule.Context{
-		SubmissionURI: submission,
-		Headers:       headers,
-		PassClient:    p.Fetcher,
-	}
-
-	return p.Rules.Resolve(context)
+func (p *policyRequest) findPolicies(sub submission, headers http.Header) ([]rule.Policy, error) {
+	return p.Rules.Resolve(p.context(sub, headers))
 }
 
 func (p *policyRequest) sendPolicies(policies []rule.Policy, err error) {
@@ -43,18 +42,9 @@ func (p *policyRequest) sendPolicies(policies []rule.Policy, err error) {
 		return
 	}
 
-	var results []PolicyResult
-	for _, policy := range policies {
-		uri, _ := p.Replace.PrivateWithPublic(policy.ID)
-		results = append(results, PolicyResult{
-			ID:   uri,
-			Type: policy.Type,
-		})
-	}
-
 	encoder := json.NewEncoder(p.resp)
 	encoder.SetIndent("", "  ")
-	err = encoder.Encode(results)
+	err = encoder.Encode(p.policyResults(policies))
 	if err != nil {
 		log.Printf("error encoding JSON response: %s", err)
 		http.Error(p.resp, err.Error(), http.StatusInternalServerError)
@@ -62,6 +52,29 @@ func (p *policyRequest) sendPolicies(policies []rule.Policy, err error) {
 	}
 }
 
+// policyResults presents resolved policies as results, with public URIs, in order of type,
+// then rank, then rule order
+func (s *PolicyService) policyResults(policies []rule.Policy) []PolicyResult {
+	rule.OrderByRank(policies)
+
+	var results []PolicyResult
+	for _, policy := range policies {
+		uri, _ := s.Replace.PrivateWithPublic(policy.ID)
+		results = append(results, PolicyResult{
+			ID:      uri,
+			Type:    policy.Type,
+			RuleID:  policy.RuleID,
+			Message: policy.Message,
+		})
+	}
+
+	if vocabulary, ok := s.Rules.(rule.PolicyTypeVocabulary); ok {
+		orderByType(results, vocabulary.PolicyTypes())
+	}
+
+	return results
+}
+
 func (p *policyRequest) handleGet() {
 	uri, ok := p.req.URL.Query()[submissionQueryParam]
 	if !ok {
@@ -77,14 +90,26 @@ func (p *policyRequest) handleGet() {
 		return
 	}
 
-	policies, err := p.findPolicies(privateSubmissionURI, p.req.Header)
+	policies, err := p.findPolicies(submission{uri: privateSubmissionURI}, p.req.Header)
 	p.sendPolicies(policies, err)
 }
 
 func (p *policyRequest) handlePost() {
+	if isJSON(p.req) {
+		sub, err := p.inlineSubmission(p.req)
+		if err != nil {
+			http.Error(p.resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		policies, err := p.findPolicies(sub, p.req.Header)
+		p.sendPolicies(policies, err)
+		return
+	}
+
 	if p.req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
 		http.Error(p.resp,
-			"expected media type application/x-www-form-urlencoded, instead got "+
+			"expected media type application/x-www-form-urlencoded or application/json, instead got "+
 				p.req.Header.Get("Content-Type"),
 			http.StatusBadRequest)
 		return
@@ -101,6 +126,27 @@ func (p *policyRequest) handlePost() {
 		return
 	}
 
-	policies, err := p.findPolicies(url, p.req.Header)
+	policies, err := p.findPolicies(submission{uri: url}, p.req.Header)
 	p.sendPolicies(policies, err)
 }
+
+// orderByType stably sorts policy results by the position of their type in the given
+// vocabulary of policy types, so that results are grouped by type.  Types not in the
+// vocabulary sort last.  Within each type, results remain in rank, then rule order.
+func orderByType(results []PolicyResult, vocabulary []string) {
+	rank := make(map[string]int, len(vocabulary))
+	for i, t := range vocabulary {
+		rank[t] = i
+	}
+
+	rankOf := func(t string) int {
+		if r, ok := rank[t]; ok {
+			return r
+		}
+		return len(vocabulary)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return rankOf(results[i].Type) < rankOf(results[j].Type)
+	})
+}
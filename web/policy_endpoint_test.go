@@ -1,9 +1,110 @@
 package web_test
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/oa-pass/pass-policy-service/web"
 )
 
 func TestPolicyEndpoint(t *testing.T) {
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-types": ["publisher", "funder", "institution"],
+		"policy-rules": [
+			{
+				"policy-id": "/policies/institution",
+				"type": "institution",
+				"repositories": [{"repository-id": "/repositories/ir"}]
+			},
+			{
+				"rule-id": "funders",
+				"policy-id": "${submission.grants.primaryFunder.policy}",
+				"type": "funder",
+				"message": "Grant ${grants.awardNumber} is funded by ${primaryFunder.name}",
+				"repositories": [{"repository-id": "${policy.repositories}"}]
+			},
+			{
+				"policy-id": "/policies/society",
+				"type": "publisher",
+				"repositories": [{"repository-id": "/repositories/society"}]
+			},
+			{
+				"policy-id": "/policies/publisher",
+				"type": "publisher",
+				"rank": 1,
+				"repositories": [{"repository-id": "/repositories/publisher"}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("rules failed validation %+v", err)
+	}
+
+	service := web.PolicyService{
+		Rules:   rules,
+		Fetcher: testFetcher(fedora),
+		Replace: baseURIs,
+	}
+
+	cases := []struct {
+		name string
+		req  *http.Request
+	}{{
+		name: "get",
+		req:  httptest.NewRequest(http.MethodGet, "/policies?submission="+url.QueryEscape(submissionURI), nil),
+	}, {
+		name: "post",
+		req: func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/policies",
+				strings.NewReader("submission="+url.QueryEscape(submissionURI)))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			return req
+		}(),
+	}, {
+		name: "post unsaved submission",
+		req: func() *http.Request {
+			req := httptest.NewRequest(http.MethodPost, "/policies",
+				strings.NewReader(`{"grants": ["`+publicBaseURI+`/grants/1"]}`))
+			req.Header.Set("Content-Type", "application/json; charset=utf-8")
+			return req
+		}(),
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			service.RequestPolicies(resp, c.req)
+
+			if resp.Code != http.StatusOK {
+				t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+			}
+
+			var results []web.PolicyResult
+			_ = json.Unmarshal(resp.Body.Bytes(), &results)
 
+			// Results are ordered by the declared vocabulary of policy types, then rank, then rule order
+			diffs := deep.Equal(results, []web.PolicyResult{
+				{ID: publicBaseURI + "/policies/publisher", Type: "publisher", RuleID: "#/policy-rules/3"},
+				{ID: publicBaseURI + "/policies/society", Type: "publisher", RuleID: "#/policy-rules/2"},
+				{
+					ID:      publicBaseURI + "/policies/nih",
+					Type:    "funder",
+					RuleID:  "funders",
+					Message: "Grant R01 1234 is funded by National Institutes of Health",
+				},
+				{ID: publicBaseURI + "/policies/institution", Type: "institution", RuleID: "#/policy-rules/0"},
+			})
+			if len(diffs) > 0 {
+				t.Fatalf("Did not get expected policies: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
 }
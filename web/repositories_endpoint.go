@@ -5,32 +5,52 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/oa-pass/pass-policy-service/rule"
 	"github.com/pkg/errors"
 )
 
+// RepositoriesResult is the response of the repositories endpoint:  the repository requirements
+// of a submission, and a debug section describing any repositories elided from them
+type RepositoriesResult struct {
+	*rule.Requirements
+	Debug *rule.KeepReport `json:"debug,omitempty"`
+}
+
 type repositoryRequest struct {
 	*PolicyService
 	req  *http.Request
 	resp http.ResponseWriter
 }
 
-func (re *repositoryRequest) handleGet() {
-	uri, ok := re.req.URL.Query()[submissionQueryParam]
-	if !ok {
-		// It would be nice to provide a pretty html page
-		http.Error(re.resp, "No submission query param provided", http.StatusBadRequest)
-		return
-	}
+const (
+	policyQueryParam  = "policy"
+	previewQueryParam = "preview"
+	lenientQueryParam = "lenient"
+)
 
-	re.performRequest(uri[0])
+func (re *repositoryRequest) handleGet() {
+	re.performRequest(re.req.URL.Query())
 }
 
 func (re *repositoryRequest) handlePost() {
+	if isJSON(re.req) {
+		sub, err := re.inlineSubmission(re.req)
+		if err != nil {
+			http.Error(re.resp, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// With the submission in the body, any other parameters are in the query
+		re.analyze(sub, re.req.URL.Query())
+		return
+	}
+
 	if re.req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
 		http.Error(re.resp,
-			"expected media type application/x-www-form-urlencoded, instead got "+
+			"expected media type application/x-www-form-urlencoded or application/json, instead got "+
 				re.req.Header.Get("Content-Type"),
 			http.StatusBadRequest)
 		return
@@ -41,18 +61,17 @@ func (re *repositoryRequest) handlePost() {
 		return
 	}
 
-	url := re.req.Form.Get(submissionQueryParam)
-	if url == "" {
+	re.performRequest(re.req.PostForm)
+}
+
+func (re *repositoryRequest) performRequest(params url.Values) {
+	publicSubmissionURI := params.Get(submissionQueryParam)
+	if publicSubmissionURI == "" {
+		// It would be nice to provide a pretty html page
 		http.Error(re.resp, "No submission value provided", http.StatusBadRequest)
 		return
 	}
 
-	re.performRequest(url)
-
-}
-
-func (re *repositoryRequest) performRequest(publicSubmissionURI string) {
-
 	// Get the submission URI on the private net
 	privateSubmissionURI, ok := re.Replace.PublicWithPrivate(publicSubmissionURI)
 	if !ok {
@@ -61,37 +80,31 @@ func (re *repositoryRequest) performRequest(publicSubmissionURI string) {
 		return
 	}
 
-	// Resolve the policies inherently implied by the submission
-	fmt.Println("Resolving policies for " + privateSubmissionURI)
-	policies, err := re.Rules.Resolve(&rule.Context{
-		SubmissionURI: privateSubmissionURI,
-		Headers:       re.req.Header,
-		PassClient:    re.Fetcher,
-	})
+	re.analyze(submission{uri: privateSubmissionURI}, params)
+}
+
+func (re *repositoryRequest) analyze(sub submission, params url.Values) {
+	analyzed, err := re.requirements(sub, re.context(sub, re.req.Header), reconcileOptionsFrom(params))
 	if err != nil {
-		log.Printf("Error resolving policies: %+v", err)
+		log.Printf("Error analyzing requirements: %+v", err)
 		http.Error(re.resp, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Find the repositories in common with between the "repositories implied by the policies
-	// inherent to the submission" vs "repositories of policies listed in effectivePolicies"
-	// These are the repositories PASS may need to deposit into.
-	privateRepoUrisToDepositInto, err := re.reconcileRepositories(privateSubmissionURI, policies)
-	if err != nil {
-		log.Printf("Error reconciling policies: %+v", err)
-		http.Error(re.resp, err.Error(), http.StatusInternalServerError)
-		return
+	result := RepositoriesResult{
+		Requirements: analyzed.requirements.
+			Recommend().
+			TranslateURIs(re.Replace.PrivateWithPublic),
+	}
+	if len(analyzed.report.Elided) > 0 {
+		result.Debug = analyzed.report.TranslateURIs(re.Replace.PrivateWithPublic)
 	}
 
-	requirements := rule.AnalyzeRequirements(policies).
-		TranslateURIs(re.Replace.PublicWithPrivate). // needed because polcies (from) may contain relative or public URIs
-		Keep(privateRepoUrisToDepositInto).
-		TranslateURIs(re.Replace.PrivateWithPublic)
+	warn(re.resp, analyzed.warnings)
 
 	encoder := json.NewEncoder(re.resp)
 	encoder.SetIndent("", "  ")
-	err = encoder.Encode(requirements)
+	err = encoder.Encode(result)
 	if err != nil {
 		log.Printf("error encoding JSON response: %s", err)
 		http.Error(re.resp, err.Error(), http.StatusInternalServerError)
@@ -99,58 +112,164 @@ func (re *repositoryRequest) performRequest(publicSubmissionURI string) {
 	}
 }
 
+// reconcileOptions determine which policies are taken to be a submission's effective policies
+type reconcileOptions struct {
+	preview  bool     // take the accepted policies as effective, rather than the submission's effectivePolicies
+	accepted []string // URIs of the accepted policies.  If empty in preview, all computed policies are accepted
+	lenient  bool     // ignore effective policies that aren't computed policies, with a warning, rather than failing
+}
+
+func reconcileOptionsFrom(params url.Values) reconcileOptions {
+	return reconcileOptions{
+		preview:  flag(params, previewQueryParam),
+		accepted: params[policyQueryParam],
+		lenient:  flag(params, lenientQueryParam),
+	}
+}
+
+// flag determines if a boolean parameter is true
+func flag(params url.Values, name string) bool {
+	value, _ := strconv.ParseBool(params.Get(name))
+	return value
+}
+
+// warn adds a Warning header to the response for each warning
+func warn(resp http.ResponseWriter, warnings []string) {
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+		resp.Header().Add("Warning", fmt.Sprintf("299 - %q", warning))
+	}
+}
+
+// analysis is the analyzed repository requirements of a submission, and how they came to be
+type analysis struct {
+	policies     []rule.Policy      // all policies that apply to the submission
+	effective    []rule.Policy      // the applicable policies that are effective
+	analyzed     *rule.Requirements // requirements of all applicable policies
+	requirements *rule.Requirements // requirements of the effective policies
+	report       *rule.KeepReport   // repositories elided from the requirements
+	warnings     []string           // problems ignored in lenient mode
+}
+
+// requirements analyzes the repository requirements of a submission, resolving its policies in
+// the given context.  Repositories of policies not among the submission's effective policies are
+// elided, as described by the accompanying report.  The resulting requirements and report contain
+// private URIs.  If the analysis fails part way, what was analyzed so far is returned with the error.
+func (s *PolicyService) requirements(sub submission, context *rule.Context,
+	options reconcileOptions) (*analysis, error) {
+
+	analyzed := &analysis{}
+
+	// Resolve the policies inherently implied by the submission
+	log.Printf("Resolving policies for %s", sub)
+	policies, err := s.Rules.Resolve(context)
+	if err != nil {
+		return analyzed, errors.Wrapf(err, "could not resolve policies")
+	}
+	analyzed.policies = policies
+
+	// Find the policies in common with between the "policies inherent to the submission" vs
+	// "policies listed in effectivePolicies".  Their repositories are the ones PASS may need
+	// to deposit into.
+	analyzed.effective, analyzed.warnings, err = s.reconcilePolicies(sub, policies, options)
+	if err != nil {
+		return analyzed, errors.Wrapf(err, "could not reconcile policies")
+	}
+
+	analyze := rule.AnalyzeRequirements
+	if analyzer, ok := s.Rules.(rule.RequirementsAnalyzer); ok {
+		analyze = analyzer.AnalyzeRequirements
+	}
+
+	// needed because polcies (from) may contain relative or public URIs
+	analyzed.analyzed = analyze(policies).TranslateURIs(s.Replace.PublicWithPrivate)
+
+	analyzed.requirements, analyzed.report = analyzed.analyzed.Keep(s.repositoriesOf(analyzed.effective))
+
+	// Policies that aren't effective don't constrain deposits
+	analyzed.requirements.ConstrainBy(analyzed.effective, s.Replace.PublicWithPrivate)
+
+	return analyzed, nil
+}
+
 type SubmissionEffectivePolicies struct {
 	PolicyURIs []string `json:"effectivePolicies"`
 }
 
-// reconcileRepositories compares all repositories implied by the policies enumerated in a
-// submission's effectivePolicies, compares it to the list of repositories enumerated by the
-// given policy list, and returns the list of repositories in common between the two
-func (re *repositoryRequest) reconcileRepositories(
-	submission string, policies []rule.Policy) ([]rule.Repository, error) {
+// reconcilePolicies matches the policies enumerated in a submission's effectivePolicies with
+// the given policy list, and returns the policies of the list that are effective.  In preview,
+// the accepted policies stand in for the submission's effectivePolicies.  In lenient mode,
+// effective policies that aren't in the given policy list are ignored, with a warning.
+func (s *PolicyService) reconcilePolicies(sub submission, policies []rule.Policy,
+	options reconcileOptions) ([]rule.Policy, []string, error) {
 
-	// first, fetch effective policies from the given submission.
-	policyData := SubmissionEffectivePolicies{}
-	err := re.Fetcher.FetchEntity(submission, &policyData)
-	if err != nil {
-		return nil, errors.Wrapf(err, "Error retrieving effective policies from submission %s", submission)
+	// first, fetch effective policies from the given submission, unless previewing
+	policyData := SubmissionEffectivePolicies{PolicyURIs: options.accepted}
+	if !options.preview {
+		err := sub.fetch(s.Fetcher, &policyData)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "Error retrieving effective policies from submission %s", sub)
+		}
+	} else if len(policyData.PolicyURIs) == 0 {
+		for _, policy := range policies {
+			policyData.PolicyURIs = append(policyData.PolicyURIs, policy.ID)
+		}
 	}
 
 	// Then build a map of known policies
 	knownPolicies := make(map[string]*rule.Policy, len(policies))
 	for i := range policies {
-		uri, _ := re.Replace.PublicWithPrivate(policies[i].ID)
+		uri, _ := s.Replace.PublicWithPrivate(policies[i].ID)
 		knownPolicies[uri] = &policies[i]
 	}
 
-	// For each effective policy from the submission, match it with a known policy, and collect the repositories
-	var commonRepositories []rule.Repository
-	encounteredRepositories := make(map[string]bool, len(policies))
+	// For each effective policy from the submission, match it with a known policy
+	var commonPolicies []rule.Policy
+	var warnings []string
 	for _, effectivePolicy := range policyData.PolicyURIs {
-		effectivePolicyURI, ok := re.Replace.PublicWithPrivate(effectivePolicy)
-		if !ok {
-			return nil, errors.Errorf("policy URI %s does not start with a public or private PASS baseuri", effectivePolicy)
+		effectivePolicyURI, ok := s.Replace.PublicWithPrivate(effectivePolicy)
+		if !ok && options.lenient {
+			warnings = append(warnings, fmt.Sprintf("ignoring policy URI %s, which does not start with a public or private PASS baseuri",
+				effectivePolicy))
+			continue
+		} else if !ok {
+			return nil, nil, errors.Errorf("policy URI %s does not start with a public or private PASS baseuri", effectivePolicy)
 		}
 
 		commonPolicy, ok := knownPolicies[effectivePolicyURI]
-		if !ok {
-			return nil, errors.Errorf("effective policy %s is not in the list of computed policies: %+v",
+		if !ok && options.lenient {
+			warnings = append(warnings, fmt.Sprintf("ignoring effective policy %s, which is not in the list of computed policies",
+				effectivePolicy))
+			continue
+		} else if !ok {
+			return nil, nil, errors.Errorf("effective policy %s is not in the list of computed policies: %+v",
 				effectivePolicyURI,
 				knownPolicies)
 		}
 
-		for _, repo := range commonPolicy.Repositories {
+		commonPolicies = append(commonPolicies, *commonPolicy)
+	}
+
+	return commonPolicies, warnings, nil
+}
+
+// repositoriesOf collects the repositories of the given policies, with private URIs
+func (s *PolicyService) repositoriesOf(policies []rule.Policy) []rule.Repository {
+	var repositories []rule.Repository
+	encounteredRepositories := make(map[string]bool, len(policies))
+	for _, policy := range policies {
+		for _, repo := range policy.Repositories {
 			if repo.ID == "*" {
 				continue
 			}
 
-			repoID, _ := re.Replace.PublicWithPrivate(repo.ID)
+			repoID, _ := s.Replace.PublicWithPrivate(repo.ID)
 			if !encounteredRepositories[repoID] {
-				commonRepositories = append(commonRepositories, rule.Repository{ID: repoID})
+				repositories = append(repositories, rule.Repository{ID: repoID})
 				encounteredRepositories[repoID] = true
 			}
 		}
 	}
 
-	return commonRepositories, nil
+	return repositories
 }
@@ -0,0 +1,298 @@
+package web_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/go-test/deep"
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/oa-pass/pass-policy-service/web"
+)
+
+func TestRepositoriesEndpoint(t *testing.T) {
+	service := repositoriesService(t, testFetcher(fedora))
+
+	resp := httptest.NewRecorder()
+	service.RequestRepositories(resp, httptest.NewRequest(http.MethodGet,
+		"/repositories?submission="+url.QueryEscape(submissionURI), nil))
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var result web.RepositoriesResult
+	_ = json.Unmarshal(resp.Body.Bytes(), &result)
+
+	// Repositories are annotated with the (public) URIs of the policies calling for them.
+	// The institution's policy isn't among the submission's effective policies, so its
+	// repository is elided.
+	diffs := deep.Equal(result, web.RepositoriesResult{Requirements: &rule.Requirements{
+		Required: []rule.Repository{{
+			ID:          publicBaseURI + "/repositories/pmc",
+			Recommended: true,
+			Sources: []rule.Source{{
+				PolicyID: publicBaseURI + "/policies/nih",
+				RuleID:   "funders",
+			}},
+		}},
+		OneOf:    [][]rule.Repository{},
+		Optional: []rule.Repository{},
+	}, Debug: &rule.KeepReport{
+		Elided: []rule.Elision{{
+			ID:          publicBaseURI + "/repositories/ir",
+			Requirement: rule.RequirementRequired,
+			Action:      rule.ElisionRemoved,
+			Reason:      "not called for by the submission's effective policies",
+			Sources: []rule.Source{{
+				PolicyID: publicBaseURI + "/policies/institution",
+				RuleID:   "#/policy-rules/1",
+			}},
+		}},
+	}})
+	if len(diffs) > 0 {
+		t.Fatalf("Did not get expected requirements: %s", strings.Join(diffs, "\n"))
+	}
+}
+
+func TestRepositoriesPreview(t *testing.T) {
+	service := repositoriesService(t, testFetcher(fedora))
+
+	cases := []struct {
+		name     string
+		params   url.Values
+		required []string
+	}{{
+		name:     "all computed policies",
+		params:   url.Values{"preview": {"true"}},
+		required: []string{"/repositories/pmc", "/repositories/ir"},
+	}, {
+		name:     "accepted policies",
+		params:   url.Values{"preview": {"true"}, "policy": {publicBaseURI + "/policies/institution"}},
+		required: []string{"/repositories/ir"},
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			c.params.Set("submission", submissionURI)
+			req := httptest.NewRequest(http.MethodPost, "/repositories", strings.NewReader(c.params.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			resp := httptest.NewRecorder()
+			service.RequestRepositories(resp, req)
+
+			if resp.Code != http.StatusOK {
+				t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+			}
+
+			var result web.RepositoriesResult
+			_ = json.Unmarshal(resp.Body.Bytes(), &result)
+
+			var required []string
+			for _, repo := range result.Required {
+				required = append(required, strings.TrimPrefix(repo.ID, publicBaseURI))
+			}
+
+			if diffs := deep.Equal(required, c.required); len(diffs) > 0 {
+				t.Fatalf("Did not get expected required repositories: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
+func TestRepositoriesInline(t *testing.T) {
+
+	// Only linked entities are in Fedora.  The submission itself is in the request.
+	content := make(map[string]string, len(fedora))
+	for uri, entity := range fedora {
+		content[uri] = entity
+	}
+	delete(content, privateBaseURI+"/submissions/1")
+
+	service := repositoriesService(t, testFetcher(content))
+
+	cases := []struct {
+		name     string
+		query    string
+		body     string
+		status   int
+		required []string
+	}{{
+		name: "effective policies",
+		body: `{
+			"grants": ["` + publicBaseURI + `/grants/1"],
+			"effectivePolicies": ["` + publicBaseURI + `/policies/nih"]
+		}`,
+		status:   http.StatusOK,
+		required: []string{"/repositories/pmc"},
+	}, {
+		name:     "preview",
+		query:    "?preview=true",
+		body:     `{"grants": ["` + publicBaseURI + `/grants/1"]}`,
+		status:   http.StatusOK,
+		required: []string{"/repositories/pmc", "/repositories/ir"},
+	}, {
+		name:   "not an object",
+		body:   `["` + publicBaseURI + `/grants/1"]`,
+		status: http.StatusBadRequest,
+	}}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/repositories"+c.query, strings.NewReader(c.body))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp := httptest.NewRecorder()
+			service.RequestRepositories(resp, req)
+
+			if resp.Code != c.status {
+				t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+			}
+
+			if c.status != http.StatusOK {
+				return
+			}
+
+			var result web.RepositoriesResult
+			_ = json.Unmarshal(resp.Body.Bytes(), &result)
+
+			var required []string
+			for _, repo := range result.Required {
+				required = append(required, strings.TrimPrefix(repo.ID, publicBaseURI))
+			}
+
+			if diffs := deep.Equal(required, c.required); len(diffs) > 0 {
+				t.Fatalf("Did not get expected required repositories: %s", strings.Join(diffs, "\n"))
+			}
+		})
+	}
+}
+
+func TestRepositoriesConstraints(t *testing.T) {
+
+	// The institution's policy constrains deposits into PMC more strictly, but isn't among the
+	// submission's effective policies
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-rules": [
+			{
+				"rule-id": "funders",
+				"policy-id": "${submission.grants.primaryFunder.policy}",
+				"type": "funder",
+				"repositories": [{
+					"repository-id": "${policy.repositories}",
+					"constraints": {"max-embargo-months": 12}
+				}]
+			},
+			{
+				"rule-id": "institution",
+				"policy-id": "/policies/institution",
+				"type": "institution",
+				"repositories": [{
+					"repository-id": "` + privateBaseURI + `/repositories/pmc",
+					"constraints": {"max-embargo-months": 0, "manuscript-versions": ["published"]}
+				}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("rules failed validation %+v", err)
+	}
+
+	service := web.PolicyService{
+		Rules:   rules,
+		Fetcher: testFetcher(fedora),
+		Replace: baseURIs,
+	}
+
+	resp := httptest.NewRecorder()
+	service.RequestRepositories(resp, httptest.NewRequest(http.MethodGet,
+		"/repositories?submission="+url.QueryEscape(submissionURI), nil))
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+	}
+
+	var result web.RepositoriesResult
+	_ = json.Unmarshal(resp.Body.Bytes(), &result)
+
+	twelve := 12
+	if len(result.Required) != 1 {
+		t.Fatalf("Expected PMC to be required, got %+v", result.Requirements)
+	}
+
+	if diffs := deep.Equal(result.Required[0].Constraints, &rule.Constraints{MaxEmbargoMonths: &twelve}); len(diffs) > 0 {
+		t.Fatalf("Did not get constraints of the effective policy: %s", strings.Join(diffs, "\n"))
+	}
+}
+
+func TestRepositoriesLenient(t *testing.T) {
+
+	// The submission's effective policies include one that the rules don't compute
+	content := make(map[string]string, len(fedora))
+	for uri, entity := range fedora {
+		content[uri] = entity
+	}
+	content[privateBaseURI+"/submissions/1"] = `{
+		"grants": ["` + privateBaseURI + `/grants/1"],
+		"effectivePolicies": ["` + publicBaseURI + `/policies/nih", "` + publicBaseURI + `/policies/unknown"]
+	}`
+
+	service := repositoriesService(t, testFetcher(content))
+
+	resp := httptest.NewRecorder()
+	service.RequestRepositories(resp, httptest.NewRequest(http.MethodGet,
+		"/repositories?submission="+url.QueryEscape(submissionURI), nil))
+
+	if resp.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected an error for an unknown effective policy, got status %d", resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	service.RequestRepositories(resp, httptest.NewRequest(http.MethodGet,
+		"/repositories?lenient=true&submission="+url.QueryEscape(submissionURI), nil))
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("Got status %d: %s", resp.Code, resp.Body.String())
+	}
+
+	warning := resp.Header().Get("Warning")
+	if !strings.HasPrefix(warning, "299 ") || !strings.Contains(warning, publicBaseURI+"/policies/unknown") {
+		t.Fatalf("Did not get expected warning: %s", warning)
+	}
+}
+
+// repositoriesService is a policy service whose rules call for the repositories of the
+// primary funder's policy, and an institutional repository
+func repositoriesService(t *testing.T, fetcher testFetcher) *web.PolicyService {
+	rules, err := rule.Validate([]byte(`{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-rules": [
+			{
+				"rule-id": "funders",
+				"policy-id": "${submission.grants.primaryFunder.policy}",
+				"type": "funder",
+				"repositories": [{"repository-id": "${policy.repositories}"}]
+			},
+			{
+				"policy-id": "/policies/institution",
+				"type": "institution",
+				"repositories": [{"repository-id": "/repositories/ir"}]
+			}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("rules failed validation %+v", err)
+	}
+
+	return &web.PolicyService{
+		Rules:   rules,
+		Fetcher: fetcher,
+		Replace: baseURIs,
+	}
+}
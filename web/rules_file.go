@@ -0,0 +1,150 @@
+package web
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/pkg/errors"
+)
+
+// RulesFile is a rules document read from a file, which can be reloaded when the file changes.
+// The rules are replaced only if the changed document is valid, so there are always valid rules.
+type RulesFile struct {
+	Path     string
+	Metrics  *Metrics // records reloads, if not nil
+	mutex    sync.RWMutex
+	rules    *rule.DSL
+	version  string
+	modified time.Time // modification time of the file when it was last read, valid or not
+	size     int64     // size of the file when it was last read, valid or not
+}
+
+// LoadRulesFile reads and validates the rules document at the given path, resolving any
+// documents it includes relative to that path
+func LoadRulesFile(path string) (*RulesFile, error) {
+	f := &RulesFile{Path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+// Rules returns the current rules, and their version
+func (f *RulesFile) Rules() (*rule.DSL, string) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return f.rules, f.version
+}
+
+// Reload reads and validates the rules document again, replacing the current rules if it is valid.
+// It reports whether the rules changed.  If the document is invalid, the current rules remain.
+func (f *RulesFile) Reload() (changed bool, err error) {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		f.Metrics.observeReload(false)
+		return false, errors.Wrapf(err, "could not read rules file %s", f.Path)
+	}
+
+	// Whether or not the document is valid, there is no need to read it again until it changes
+	f.mutex.Lock()
+	f.modified = info.ModTime()
+	f.size = info.Size()
+	f.mutex.Unlock()
+
+	rules, err := rule.ValidateFile(f.Path)
+	if err != nil {
+		f.Metrics.observeReload(false)
+		return false, errors.Wrapf(err, "could not validate rules dsl")
+	}
+
+	version, err := rulesVersion(rules)
+	if err != nil {
+		f.Metrics.observeReload(false)
+		return false, err
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	changed = version != f.version
+	f.rules = rules
+	f.version = version
+
+	f.Metrics.observeReload(true)
+	f.Metrics.SetRulesVersion(version)
+	return changed, nil
+}
+
+// Watch polls the rules file at the given interval, reloading it when it is modified, until
+// stopped.  Reloads are also requested by sending to the reload channel (e.g. on SIGHUP), in
+// which case the file is reloaded whether or not it was modified, e.g. to pick up changes to
+// the documents it includes.  If the interval is zero, the file is not polled.
+func (f *RulesFile) Watch(interval time.Duration, reload <-chan os.Signal, stop <-chan struct{}) {
+	var poll <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-reload:
+			f.reloadAndLog()
+		case <-poll:
+			if f.isModified() {
+				f.reloadAndLog()
+			}
+		}
+	}
+}
+
+// isModified determines if the rules file has been modified since it was last read
+func (f *RulesFile) isModified() bool {
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		log.Printf("Could not check rules file %s for changes: %s", f.Path, err)
+		return false
+	}
+
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	return !info.ModTime().Equal(f.modified) || info.Size() != f.size
+}
+
+func (f *RulesFile) reloadAndLog() {
+	changed, err := f.Reload()
+	_, version := f.Rules()
+
+	switch {
+	case err != nil:
+		log.Printf("Rejected rules from %s, keeping version %s: %+v", f.Path, version, err)
+	case changed:
+		log.Printf("Reloaded rules from %s, now version %s", f.Path, version)
+	default:
+		log.Printf("Reloaded rules from %s, still version %s", f.Path, version)
+	}
+}
+
+// rulesVersion identifies a set of rules by the digest of their content, including the content of
+// any documents they include
+func rulesVersion(rules *rule.DSL) (string, error) {
+	content, err := json.Marshal(rules)
+	if err != nil {
+		return "", errors.Wrapf(err, "could not encode rules")
+	}
+
+	digest := sha256.Sum256(content)
+	return hex.EncodeToString(digest[:6]), nil
+}
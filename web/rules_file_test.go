@@ -0,0 +1,174 @@
+package web_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oa-pass/pass-policy-service/web"
+)
+
+// rulesDoc is a rules document with a single rule, for the given policy
+func rulesDoc(policy string) string {
+	return `{
+		"$schema": "https://oa-pass.github.io/pass-policy-service/schemas/policy_config_2.0.json",
+		"policy-rules": [
+			{
+				"policy-id": "` + policy + `",
+				"type": "institution",
+				"repositories": [{"repository-id": "/repositories/ir"}]
+			}
+		]
+	}`
+}
+
+func writeRules(t *testing.T, path, content string) {
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("could not write rules file: %+v", err)
+	}
+}
+
+func TestRulesFileReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.json")
+	writeRules(t, path, rulesDoc("/policies/a"))
+
+	service, err := web.NewPolicyServiceFromFile(path, testFetcher(fedora))
+	if err != nil {
+		t.Fatalf("could not create service: %+v", err)
+	}
+	service.Replace = baseURIs
+
+	// policy requests the service, returning the ID of the one policy found, and the rules version
+	policy := func() (string, string) {
+		resp := httptest.NewRecorder()
+		service.RequestPolicies(resp, httptest.NewRequest(http.MethodGet,
+			"/policies?submission="+url.QueryEscape(submissionURI), nil))
+
+		var results []web.PolicyResult
+		_ = json.Unmarshal(resp.Body.Bytes(), &results)
+		if len(results) != 1 {
+			t.Fatalf("Expected one policy, got %+v", results)
+		}
+		return results[0].ID, resp.Header().Get("X-Rules-Version")
+	}
+
+	originalPolicy, originalVersion := policy()
+	if originalPolicy != publicBaseURI+"/policies/a" || originalVersion == "" {
+		t.Fatalf("Did not get original rules: %s version %s", originalPolicy, originalVersion)
+	}
+
+	// Unchanged
+	changed, err := service.RulesFile.Reload()
+	if err != nil || changed {
+		t.Fatalf("Expected rules to be unchanged, got changed=%t, %+v", changed, err)
+	}
+
+	// Invalid rules are rejected, and the original ones kept
+	writeRules(t, path, `{"policy-rules": "nope"}`)
+	if _, err = service.RulesFile.Reload(); err == nil {
+		t.Fatalf("Expected invalid rules to be rejected")
+	}
+
+	if p, v := policy(); p != originalPolicy || v != originalVersion {
+		t.Fatalf("Expected original rules to be kept, got %s version %s", p, v)
+	}
+
+	// Valid rules replace the original ones
+	writeRules(t, path, rulesDoc("/policies/b"))
+	changed, err = service.RulesFile.Reload()
+	if err != nil || !changed {
+		t.Fatalf("Expected rules to be changed, got changed=%t, %+v", changed, err)
+	}
+
+	if p, v := policy(); p != publicBaseURI+"/policies/b" || v == originalVersion {
+		t.Fatalf("Expected reloaded rules, got %s version %s", p, v)
+	}
+}
+
+func TestRulesFileWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rules")
+	if err != nil {
+		t.Fatalf("could not create temp dir: %+v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "rules.json")
+	writeRules(t, path, rulesDoc("/policies/a"))
+
+	rules, err := web.LoadRulesFile(path)
+	if err != nil {
+		t.Fatalf("could not load rules: %+v", err)
+	}
+	_, originalVersion := rules.Rules()
+
+	metrics := web.NewMetrics()
+	rules.Metrics = metrics
+
+	reload := make(chan os.Signal)
+	stop := make(chan struct{})
+	defer close(stop)
+	go rules.Watch(10*time.Millisecond, reload, stop)
+
+	// A modified file is noticed.  Make sure it looks modified, even on coarse file systems.
+	writeRules(t, path, rulesDoc("/policies/modified"))
+	later := time.Now().Add(time.Minute)
+	_ = os.Chtimes(path, later, later)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		dsl, version := rules.Rules()
+		if version != originalVersion && dsl.Policies[0].ID == "/policies/modified" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Modified rules were not reloaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// An invalid edit is rejected once, not every time the file is polled.  The edit is moved into
+	// place, so that it isn't polled part way.
+	edit := filepath.Join(dir, "edit.json")
+	writeRules(t, edit, `{"policy-rules": "nope"}`)
+	later = later.Add(time.Minute)
+	_ = os.Chtimes(edit, later, later)
+	if err := os.Rename(edit, path); err != nil {
+		t.Fatalf("could not edit rules file: %+v", err)
+	}
+
+	rejected := `policy_service_rules_reloads_total{result="failure"} 1` + "\n"
+	deadline = time.Now().Add(5 * time.Second)
+	for !strings.Contains(exposition(metrics), rejected) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Invalid rules were not rejected: %s", exposition(metrics))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if !strings.Contains(exposition(metrics), rejected) {
+		t.Fatalf("Invalid rules were rejected more than once: %s", exposition(metrics))
+	}
+}
+
+// exposition requests metrics in the Prometheus text format
+func exposition(metrics *web.Metrics) string {
+	service := web.PolicyService{Metrics: metrics}
+
+	resp := httptest.NewRecorder()
+	service.RequestMetrics(resp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return resp.Body.String()
+}
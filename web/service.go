@@ -8,11 +8,30 @@ import (
 )
 
 type PolicyService struct {
+	// Rules are the policy rules, unless there is a RulesFile.  Handlers read the rules of the
+	// service returned by current, never those of the service itself
 	Rules   rule.PolicyResolver
 	Fetcher rule.PassEntityFetcher
 	Replace BaseURIReplacer
+
+	// RulesFile is the file the rules are read from, if any.  If present, each request uses its
+	// current rules, and Rules is ignored, so that the rules may be reloaded as the service runs
+	RulesFile *RulesFile
+
+	// BatchWorkers is the number of submissions of a batch evaluated at once.  If zero, a default is used
+	BatchWorkers int
+
+	// Admin is the credentials required by administrative endpoints, e.g. explain.  If nil, they are disabled
+	Admin *Credentials
+
+	// Metrics records measurements of the service.  If nil, nothing is recorded
+	Metrics *Metrics
+
+	rulesVersion string // version of the rules from RulesFile, if any
 }
 
+const headerRulesVersion = "X-Rules-Version"
+
 type requestHandler interface {
 	handleGet()
 	handlePost()
@@ -29,16 +48,67 @@ func NewPolicyService(rulesDoc []byte, fetcher rule.PassEntityFetcher) (service
 	return service, nil
 }
 
+// NewPolicyServiceFromFile creates a policy service using the rules document at the given path,
+// resolving any documents it includes relative to that path.
+func NewPolicyServiceFromFile(rulesFile string, fetcher rule.PassEntityFetcher) (service PolicyService, err error) {
+
+	service = PolicyService{Fetcher: fetcher}
+	service.RulesFile, err = LoadRulesFile(rulesFile)
+	if err != nil {
+		return service, err
+	}
+
+	return service, nil
+}
+
+// current returns the service as it is to handle a request:  with the current rules from the
+// rules file, if there is one, so that the request uses the same rules throughout
+func (s *PolicyService) current() *PolicyService {
+	if s.RulesFile == nil {
+		return s
+	}
+
+	current := *s
+	current.Rules, current.rulesVersion = s.rules()
+	return &current
+}
+
+// rules returns the current rules and their version:  those of the rules file, if there is one,
+// or else Rules.  The result is nil if no rules are loaded.
+func (s *PolicyService) rules() (rule.PolicyResolver, string) {
+	if s.RulesFile == nil {
+		return s.Rules, s.rulesVersion
+	}
+
+	// Don't hide a nil *rule.DSL in a non-nil interface
+	dsl, version := s.RulesFile.Rules()
+	if dsl == nil {
+		return nil, version
+	}
+	return dsl, version
+}
+
 func (s *PolicyService) RequestPolicies(w http.ResponseWriter, r *http.Request) {
+	s = s.current()
 	s.doRequest(&policyRequest{s, r, w}, w, r)
 }
 
 func (s *PolicyService) RequestRepositories(w http.ResponseWriter, r *http.Request) {
+	s = s.current()
 	s.doRequest(&repositoryRequest{s, r, w}, w, r)
 }
 
+// RequestCompliance checks a choice of repositories against the requirements of a submission
+func (s *PolicyService) RequestCompliance(w http.ResponseWriter, r *http.Request) {
+	s = s.current()
+	s.doRequest(&complianceRequest{s, r, w}, w, r)
+}
+
 func (s *PolicyService) doRequest(handler requestHandler, w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	if s.rulesVersion != "" {
+		w.Header().Set(headerRulesVersion, s.rulesVersion)
+	}
 	switch r.Method {
 	case http.MethodGet:
 		handler.handleGet()
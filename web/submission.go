@@ -0,0 +1,86 @@
+package web
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+
+	"github.com/oa-pass/pass-policy-service/rule"
+	"github.com/pkg/errors"
+)
+
+const jsonMediaType = "application/json"
+
+// submission identifies a submission to evaluate policies for:  by its private URI, and by its
+// content, if it was provided inline rather than stored in Fedora
+type submission struct {
+	uri    string                 // private URI.  Empty for an inline submission that hasn't been saved
+	entity map[string]interface{} // inline content, or nil if the submission is to be fetched from its URI
+}
+
+// isJSON determines if the body of a request is JSON
+func isJSON(req *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	return mediaType == jsonMediaType
+}
+
+// inlineSubmission reads a submission entity from the JSON body of a request.  If the entity has
+// an ID (e.g. it is a modified version of a stored submission), that is its URI.
+func (s *PolicyService) inlineSubmission(req *http.Request) (submission, error) {
+	var entity map[string]interface{}
+	if err := json.NewDecoder(req.Body).Decode(&entity); err != nil {
+		return submission{}, errors.Wrapf(err, "could not parse submission JSON")
+	}
+	if entity == nil {
+		return submission{}, errors.New("submission JSON is not an object")
+	}
+
+	var uri string
+	for _, key := range []string{"@id", "id"} {
+		if id, ok := entity[key].(string); ok && id != "" {
+			uri, _ = s.Replace.PublicWithPrivate(id)
+			break
+		}
+	}
+
+	return submission{uri: uri, entity: entity}, nil
+}
+
+// context establishes a rule evaluation context for the submission, which counts the policies
+// each rule produces
+func (s *PolicyService) context(sub submission, headers http.Header) *rule.Context {
+	return &rule.Context{
+		SubmissionURI: sub.uri,
+		Submission:    sub.entity,
+		Headers:       headers,
+		PassClient:    s.Fetcher,
+		Matched:       s.Metrics.observeMatch,
+	}
+}
+
+// fetch un-marshals the content of the submission into the provided struct, fetching it from
+// Fedora unless it was provided inline
+func (sub submission) fetch(fetcher rule.PassEntityFetcher, entityPointer interface{}) error {
+	if sub.entity == nil {
+		return fetcher.FetchEntity(sub.uri, entityPointer)
+	}
+
+	content, err := json.Marshal(sub.entity)
+	if err != nil {
+		return errors.Wrapf(err, "could not encode submission")
+	}
+
+	return json.Unmarshal(content, entityPointer)
+}
+
+// String describes the submission, for logging
+func (sub submission) String() string {
+	switch {
+	case sub.entity == nil:
+		return sub.uri
+	case sub.uri == "":
+		return "unsaved submission"
+	default:
+		return "inline submission " + sub.uri
+	}
+}
@@ -0,0 +1,53 @@
+package web_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/oa-pass/pass-policy-service/web"
+)
+
+const (
+	publicBaseURI  = "https://pass.example.org/fcrepo/rest"
+	privateBaseURI = "http://fcrepo:8080/fcrepo/rest"
+	submissionURI  = publicBaseURI + "/submissions/1"
+)
+
+var baseURIs = web.BaseURIs{
+	Public:  publicBaseURI,
+	Private: privateBaseURI,
+}
+
+// Fedora content of a submission with a single NIH grant, on the private network
+var fedora = map[string]string{
+	privateBaseURI + "/submissions/1": `{
+		"grants": ["` + privateBaseURI + `/grants/1"],
+		"effectivePolicies": ["` + publicBaseURI + `/policies/nih"]
+	}`,
+	privateBaseURI + "/grants/1": `{
+		"awardNumber": "R01 1234",
+		"primaryFunder": "` + privateBaseURI + `/funders/nih"
+	}`,
+	privateBaseURI + "/funders/nih": `{
+		"name": "National Institutes of Health",
+		"policy": "` + privateBaseURI + `/policies/nih"
+	}`,
+	privateBaseURI + "/policies/nih": `{
+		"repositories": ["` + privateBaseURI + `/repositories/pmc"]
+	}`,
+}
+
+// map of private urls to json strings
+type testFetcher map[string]string
+
+// deserialize the json string into the given entity pointer.  Like InternalPassClient,
+// public URLs are translated to private URLs.
+func (f testFetcher) FetchEntity(url string, entityPointer interface{}) error {
+	url, _ = baseURIs.PublicWithPrivate(url)
+	jsonBlob, ok := f[url]
+	if !ok {
+		return fmt.Errorf("no value for key %s", url)
+	}
+
+	return json.Unmarshal([]byte(jsonBlob), entityPointer)
+}